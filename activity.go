@@ -0,0 +1,51 @@
+package govkbot
+
+import (
+	"time"
+)
+
+// Activity types for SetActivity
+const (
+	ActivityTyping       = "typing"
+	ActivityAudioMessage = "audiomessage"
+	ActivityPhoto        = "photo"
+	ActivityVideo        = "video"
+	ActivityFile         = "file"
+)
+
+// typingRepeat - VK shows activity ~10 seconds, so repeat it while handler works
+var typingRepeat = 5 * time.Second
+
+// SetTypingThreshold - show "typing" in peer while handler runs longer than threshold. Zero disables
+func (bot *VKBot) SetTypingThreshold(threshold time.Duration) {
+	bot.typingAfter = threshold
+}
+
+// startTyping - starts typing timer for message, returns stop func
+func (bot *VKBot) startTyping(m *Message) func() {
+	if bot.typingAfter <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		timer := time.NewTimer(bot.typingAfter)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-timer.C:
+				if err := bot.API.SetActivity(m.peer(), ActivityTyping); err != nil {
+					bot.log().Warn("set typing", "peer_id", m.peer(), "error", err)
+				}
+				timer.Reset(typingRepeat)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
@@ -0,0 +1,92 @@
+package govkbot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVkAPI_SetActivity(t *testing.T) {
+	SetAPI("", "test", "")
+	if err := API.SetActivity(1, ActivityTyping); err != nil {
+		t.Error(err.Error())
+	}
+	if err := API.MarkAsRead(1, 10); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestMessage_peer(t *testing.T) {
+	cases := []struct {
+		m    Message
+		peer int64
+	}{
+		{Message{PeerID: 5, UserID: 1}, 5},
+		{Message{ChatID: 3, UserID: 1}, ChatPrefix + 3},
+		{Message{ChatID: ChatPrefix + 3, UserID: 1}, ChatPrefix + 3},
+		{Message{UserID: 1}, 1},
+	}
+	for _, c := range cases {
+		if p := c.m.peer(); p != c.peer {
+			t.Errorf("wrong peer %d for %+v", p, c.m)
+		}
+	}
+}
+
+func TestVKBot_SetTypingThreshold(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		method := strings.TrimPrefix(r.URL.Path, "/method/")
+		mu.Lock()
+		if method == apiMessagesSetActivity {
+			calls = append(calls, method+":"+r.Form.Get("peer_id")+":"+r.Form.Get("type"))
+		} else {
+			calls = append(calls, method)
+		}
+		mu.Unlock()
+		w.Write([]byte(`{"response": 1}`))
+	}))
+	defer srv.Close()
+	typingRepeat = 2 * time.Millisecond
+	defer func() { typingRepeat = 5 * time.Second }()
+
+	bot := (&VkAPI{Token: "token", URL: srv.URL + "/method/", GroupID: 1}).NewBot()
+	bot.SetTypingThreshold(5 * time.Millisecond)
+	bot.Handle("/slow", func(c *Context) error {
+		time.Sleep(30 * time.Millisecond)
+		c.Reply("done")
+		return nil
+	})
+	bot.Handle("/fast", func(c *Context) error {
+		c.Reply("done")
+		return nil
+	})
+	bot.ProcessMessage(context.Background(), &Message{PeerID: 1, UserID: 1, Body: "/slow"})
+	time.Sleep(10 * time.Millisecond)
+
+	mu.Lock()
+	slowCalls := append([]string(nil), calls...)
+	calls = nil
+	mu.Unlock()
+	if len(slowCalls) < 3 || slowCalls[len(slowCalls)-1] != apiMessagesSend {
+		t.Fatalf("typing not stopped before reply: %v", slowCalls)
+	}
+	for _, call := range slowCalls[:len(slowCalls)-1] {
+		if call != apiMessagesSetActivity+":1:"+ActivityTyping {
+			t.Errorf("wrong typing call: %v", slowCalls)
+		}
+	}
+
+	bot.ProcessMessage(context.Background(), &Message{PeerID: 1, UserID: 1, Body: "/fast"})
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != 1 || calls[0] != apiMessagesSend {
+		t.Errorf("typing sent for fast handler: %v", calls)
+	}
+}
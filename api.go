@@ -5,13 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"math/rand"
 	"net/http"
 	"net/url"
 	"strconv"
-	"strings"
 	"time"
-	"unicode/utf8"
 )
 
 // H - simple object struct
@@ -29,7 +28,11 @@ type VkAPI struct {
 	AdminID         int64
 	MessagesCount   int
 	RequestInterval int
-	DEBUG           bool
+	DEBUG           bool         // debug logs to stderr if Logger not set
+	Logger          *slog.Logger // library logger, slog.Default if nil
+	HTTPClient      *http.Client // used for API, long poll and upload requests, http.DefaultClient if nil
+	cache           *lruCache
+	cacheTTL        time.Duration
 }
 
 const (
@@ -41,6 +44,12 @@ const (
 	apiMessagesGetChatUsers           = "messages.getChatUsers"
 	apiMessagesGetConversationMembers = "messages.getConversationMembers"
 	apiMessagesSend                   = "messages.send"
+	apiMessagesEdit                   = "messages.edit"
+	apiMessagesDelete                 = "messages.delete"
+	apiMessagesPin                    = "messages.pin"
+	apiMessagesUnpin                  = "messages.unpin"
+	apiMessagesGetByConversationMsgID = "messages.getByConversationMessageId"
+	apiMessagesSetActivity            = "messages.setActivity"
 	apiMessagesMarkARead              = "messages.markAsRead"
 	apiFriendsGetRequests             = "friends.getRequests"
 	apiFriendsAdd                     = "friends.add"
@@ -57,11 +66,11 @@ func (api *VkAPI) IsGroup() bool {
 		return false
 	}
 
-	g, err := API.CurrentGroup()
+	g, err := api.CurrentGroup()
 	if err != nil || g.ID == 0 {
-		u, err := API.Me()
+		u, err := api.Me()
 		if err != nil || u == nil {
-			fmt.Printf("Get current user/group error %+v\n", err)
+			api.logger().Error("get current user or group", "error", err)
 		} else {
 			api.UID = u.ID
 		}
@@ -71,9 +80,17 @@ func (api *VkAPI) IsGroup() bool {
 	return api.GroupID != 0
 }
 
+// httpClient - configured http client or default
+func (api *VkAPI) httpClient() *http.Client {
+	if api.HTTPClient != nil {
+		return api.HTTPClient
+	}
+	return http.DefaultClient
+}
+
 // Call - main api call method
 func (api *VkAPI) Call(method string, params map[string]string) ([]byte, error) {
-	debugPrint("vk req: %+v params: %+v\n", api.URL+method, params)
+	logger := api.logger()
 	params["access_token"] = api.Token
 	params["v"] = api.Ver
 	if api.Lang != "" {
@@ -92,15 +109,17 @@ func (api *VkAPI) Call(method string, params map[string]string) ([]byte, error)
 		content, err := ioutil.ReadFile("./mocks/" + method + ".json")
 		return content, err
 	}
-	resp, err := http.PostForm(api.URL+method, parameters)
+	start := time.Now()
+	resp, err := api.httpClient().PostForm(api.URL+method, parameters)
 	if err != nil {
-		debugPrint("%+v\n", err.Error())
+		logger.Warn("vk request failed", "method", method, "latency", time.Since(start), "error", err)
 		time.Sleep(time.Duration(time.Millisecond * time.Duration(api.RequestInterval)))
 		return nil, err
 	}
 	buf, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	logger.Debug("vk request", "method", method, paramsAttr(params), "latency", time.Since(start), "bytes", len(buf))
 	time.Sleep(time.Duration(time.Millisecond * time.Duration(api.RequestInterval)))
-	debugPrint("vk resp: %+v\n", string(buf))
 
 	return buf, err
 }
@@ -117,7 +136,7 @@ func (api *VkAPI) CallMethod(method string, params map[string]string, result int
 		return &ResponseError{errors.New("vkapi: vk response is not json"), string(buf)}
 	}
 	if r.Error != nil {
-		debugPrint("%+v\n", r.Error.ErrorMsg)
+		api.logger().Debug("vk error", "method", method, "error_code", r.Error.ErrorCode, "error", r.Error.ErrorMsg)
 		return r.Error
 	}
 
@@ -126,6 +145,8 @@ func (api *VkAPI) CallMethod(method string, params map[string]string, result int
 }
 
 // GetMessages - get user messages (up to 200)
+//
+// Deprecated: messages.get removed from VK API, use Conversations and History
 func (api *VkAPI) GetMessages(count int, offset int) (*Messages, error) {
 
 	m := MessagesResponse{}
@@ -144,7 +165,7 @@ func (api *VkAPI) Me() (*User, error) {
 	err := api.CallMethod(apiUsersGet, H{"fields": "screen_name"}, &r)
 
 	if len(r.Response) > 0 {
-		debugPrint("me: %+v - %+v\n", r.Response[0].ID, r.Response[0].ScreenName)
+		api.logger().Debug("current user", "user_id", r.Response[0].ID, "screen_name", r.Response[0].ScreenName)
 		return r.Response[0], err
 	}
 	return nil, err
@@ -152,13 +173,20 @@ func (api *VkAPI) Me() (*User, error) {
 
 // CurrentGroup - get current group info
 func (api *VkAPI) CurrentGroup() (*GroupProfile, error) {
+	if cached, ok := api.cacheGet("group:current"); ok {
+		group := cached.(GroupProfile)
+		return &group, nil
+	}
 
 	r := MembersResponse{}
 	err := api.CallMethod(apiGroupsGet, H{"fields": "screen_name"}, &r)
 
 	if len(r.Response.Groups) > 0 {
 		group := r.Response.Groups[0]
-		debugPrint("me: %+v - %+v\n", group.ID, group.ScreenName)
+		api.logger().Debug("current group", "group_id", group.ID, "screen_name", group.ScreenName)
+		if err == nil {
+			api.cacheSet("group:current", group)
+		}
 		return &group, err
 	}
 	return nil, err
@@ -215,10 +243,25 @@ func (api *VkAPI) GetConversation(chatID int64) (*ChatInfo, error) {
 
 // GetChatInfo - returns Chat info by id
 func (api *VkAPI) GetChatInfo(chatID int64) (*ChatInfo, error) {
+	key := api.chatArgCacheKey(chatID) + "info"
+	if cached, ok := api.cacheGet(key); ok {
+		info := cached.(ChatInfo)
+		info.Users = cloneUsers(info.Users)
+		return &info, nil
+	}
+	var info *ChatInfo
+	var err error
 	if api.IsGroup() {
-		return api.GetConversation(chatID)
+		info, err = api.GetConversation(chatID)
+	} else {
+		info, err = api.GetUserChatInfo(chatID)
+	}
+	if err == nil && info != nil {
+		cached := *info
+		cached.Users = cloneUsers(info.Users)
+		api.cacheSet(key, cached)
 	}
-	return api.GetUserChatInfo(chatID)
+	return info, err
 }
 
 func (api *VkAPI) GetChatFullInfo(chatID int64) (*ChatInfo, error) {
@@ -285,10 +328,19 @@ func (api *VkAPI) GetConversationMembers(chatID int64) (users []*User, err error
 }
 
 func (api *VkAPI) GetChatUsers(chatID int64) (users []*User, err error) {
+	key := api.chatArgCacheKey(chatID) + "users"
+	if cached, ok := api.cacheGet(key); ok {
+		return cloneUsers(cached.([]*User)), nil
+	}
 	if api.IsGroup() {
-		return api.GetConversationMembers(chatID)
+		users, err = api.GetConversationMembers(chatID)
+	} else {
+		users, err = api.GetUserChatUsers(chatID)
+	}
+	if err == nil {
+		api.cacheSet(key, cloneUsers(users))
 	}
-	return api.GetUserChatUsers(chatID)
+	return users, err
 }
 
 func FindUser(users []*User, ID int64) *User {
@@ -340,141 +392,251 @@ func (api *VkAPI) DeleteFriend(uid int64) bool {
 
 // User - get simple user info
 func (api *VkAPI) User(uid int64) (*User, error) {
-
-	r := UsersResponse{}
-	err := api.CallMethod(apiUsersGet, H{
-		"user_ids": strconv.FormatInt(uid, 10),
-		"fields":   "sex,screen_name, city, country, bdate",
-	}, &r)
-
+	users, err := api.Users([]int64{uid}, DefaultUserFields, "")
 	if err != nil {
 		return nil, err
 	}
-	if len(r.Response) > 0 {
-		return r.Response[0], err
+	if len(users) > 0 {
+		return users[0], nil
 	}
 	return nil, errors.New("no users returned")
 }
 
-// MarkAsRead - mark message as read
+// MarkAsRead - mark message and all previous messages in peer as read
 func (m Message) MarkAsRead() (err error) {
+	return API.MarkAsRead(m.peer(), m.ID)
+}
 
-	//r := SimpleResponse{}
-	//err = API.CallMethod(apiMessagesMarkAsRead, H{"message_ids": strconv.Itoa(m.ID)}, &r)
-	return nil
+// MarkAsRead - mark messages in peer as read, starting from startMessageID. Zero marks whole peer
+func (api *VkAPI) MarkAsRead(peerID int64, startMessageID int64) error {
+	params := H{"peer_id": strconv.FormatInt(peerID, 10)}
+	if startMessageID != 0 {
+		params["start_message_id"] = strconv.FormatInt(startMessageID, 10)
+	}
+	if api.IsGroup() && api.GroupID != 0 {
+		params["group_id"] = strconv.FormatInt(api.GroupID, 10)
+	}
+	r := SimpleResponse{}
+	return api.CallMethod(apiMessagesMarkARead, params, &r)
 }
 
-func (m Message) GetMentions() []Mention {
-	mentions := make([]Mention, 0)
-	ok := false
-	ustr := ""
-	uname := ""
-	msg := m.Body
-	i := strings.Index(msg, "[id")
-	imax := len(msg)
-	if i >= 0 {
-		i += 2
-		for {
-			i++
-			if i >= imax {
-				break
-			}
-			if '0' <= msg[i] && msg[i] <= '9' {
-				ustr += string(msg[i])
-			} else {
-				ok = true
-				break
-			}
+// SetActivity - show activity status (ActivityTyping, ActivityAudioMessage...) in peer for ~10 seconds
+func (api *VkAPI) SetActivity(peerID int64, activity string) error {
+	params := H{
+		"peer_id": strconv.FormatInt(peerID, 10),
+		"type":    activity,
+	}
+	if api.IsGroup() && api.GroupID != 0 {
+		params["group_id"] = strconv.FormatInt(api.GroupID, 10)
+	}
+	r := SimpleResponse{}
+	return api.CallMethod(apiMessagesSetActivity, params, &r)
+}
+
+func (api *VkAPI) GetRandomID() string {
+	return strconv.FormatUint(uint64(rand.Uint32()), 10)
+}
+
+// SendAdvancedPeerMessage sending a message to chat. Returns message id of last part,
+// use SendPeerReply to get conversation_message_id too
+func (api *VkAPI) SendAdvancedPeerMessage(peerID int64, message Reply) (id int64, err error) {
+	sent, err := api.SendPeerReply(peerID, message)
+	return sent.MessageID, err
+}
+
+// SendPeerReply - send reply to peer.
+// Group tokens send in peer_ids mode, so conversation_message_id is returned too.
+// Long messages are split to parts, keyboard or template is attached to last part. Last sent part returned
+func (api *VkAPI) SendPeerReply(peerID int64, message Reply) (sent SentMessage, err error) {
+	extra, err := replyParams(message)
+	if err != nil {
+		return sent, err
+	}
+	parts := SplitMessage(SanitizeMessage(message.Msg), MaxMessageLength)
+	for i, part := range parts {
+		params := H{
+			"message":          part,
+			"dont_parse_links": "1",
+			"random_id":        api.GetRandomID(),
 		}
-		w := 1
-		var runeValue rune
-		for {
-			i += w
-			if i >= imax {
-				ok = false
-				break
+		if api.IsGroup() {
+			params["peer_ids"] = strconv.FormatInt(peerID, 10)
+		} else {
+			params["peer_id"] = strconv.FormatInt(peerID, 10)
+		}
+		if i == len(parts)-1 {
+			for k, v := range extra {
+				params[k] = v
 			}
-			runeValue, w = utf8.DecodeRuneInString(msg[i:])
-			if msg[i] != ']' {
-				if msg[i] != '|' {
-					uname += fmt.Sprintf("%c", runeValue) //string(msg[i])
-				}
-			} else {
-				ok = true
-				break
+		}
+		sent, err = api.sendPart(peerID, params)
+		if err != nil {
+			if len(parts) > 1 {
+				err = fmt.Errorf("vkapi: send part %d of %d to peer %d: %w", i+1, len(parts), peerID, err)
 			}
+			return sent, err
 		}
 	}
-	if ok {
-		u, err := strconv.ParseInt(ustr, 10, 64)
-		if err == nil {
-			mentions = append(mentions, Mention{u, uname})
-		}
+	return sent, nil
+}
+
+func (api *VkAPI) sendPart(peerID int64, params H) (sent SentMessage, err error) {
+	messages, err := api.send(params)
+	if err != nil {
+		return sent, err
+	}
+	if len(messages) == 0 {
+		return sent, errors.New("vkapi: no messages sent")
 	}
-	return mentions
+	sent = messages[0]
+	if sent.PeerID == 0 {
+		sent.PeerID = peerID
+	}
+	if sent.Error != nil {
+		return sent, sent.Error
+	}
+	return sent, nil
 }
 
-func (api *VkAPI) GetRandomID() string {
-	return strconv.FormatUint(uint64(rand.Uint32()), 10)
+func (api *VkAPI) send(params H) ([]SentMessage, error) {
+	r := RawResponse{}
+	err := api.CallMethod(apiMessagesSend, params, &r)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Response) > 0 && r.Response[0] == '[' {
+		var messages []SentMessage
+		err = json.Unmarshal(r.Response, &messages)
+		return messages, err
+	}
+	sent := SentMessage{}
+	err = json.Unmarshal(r.Response, &sent.MessageID)
+	return []SentMessage{sent}, err
 }
 
-// SendAdvancedPeerMessage sending a message to chat
-func (api *VkAPI) SendAdvancedPeerMessage(peerID int64, message Reply) (id int64, err error) {
+// EditMessage - edit message by message id
+func (api *VkAPI) EditMessage(peerID int64, messageID int64, message Reply) error {
+	return api.editMessage(peerID, "message_id", messageID, message)
+}
+
+// EditConversationMessage - edit message by conversation_message_id (group tokens can edit only so)
+func (api *VkAPI) EditConversationMessage(peerID int64, conversationMessageID int64, message Reply) error {
+	return api.editMessage(peerID, "conversation_message_id", conversationMessageID, message)
+}
+
+func (api *VkAPI) editMessage(peerID int64, idParam string, id int64, message Reply) error {
+	params := H{
+		"peer_id":               strconv.FormatInt(peerID, 10),
+		idParam:                 strconv.FormatInt(id, 10),
+		"message":               message.Msg,
+		"dont_parse_links":      "1",
+		"keep_forward_messages": "1",
+	}
+	extra, err := replyParams(message)
+	if err != nil {
+		return err
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
 	r := SimpleResponse{}
+	return api.CallMethod(apiMessagesEdit, params, &r)
+}
+
+// DeleteMessages - delete messages by ids. deleteForAll deletes for all chat members (required for groups)
+func (api *VkAPI) DeleteMessages(peerID int64, messageIDs []int64, deleteForAll bool) error {
+	return api.deleteMessages(peerID, "message_ids", messageIDs, deleteForAll)
+}
+
+// DeleteConversationMessages - delete messages by conversation_message_id
+func (api *VkAPI) DeleteConversationMessages(peerID int64, conversationMessageIDs []int64, deleteForAll bool) error {
+	return api.deleteMessages(peerID, "cmids", conversationMessageIDs, deleteForAll)
+}
+
+func (api *VkAPI) deleteMessages(peerID int64, idsParam string, ids []int64, deleteForAll bool) error {
 	params := H{
-		"peer_id":          strconv.FormatInt(peerID, 10),
-		"message":          message.Msg,
-		"dont_parse_links": "1",
-		"random_id":        api.GetRandomID(),
+		"peer_id": strconv.FormatInt(peerID, 10),
+		idsParam:  joinIDs(ids),
 	}
-	if message.Keyboard != nil {
-		keyboard, err := json.Marshal(message.Keyboard)
-		if err != nil {
-			fmt.Printf("ERROR encode keyboard %+v\n", message.Keyboard)
-		} else {
-			params["keyboard"] = string(keyboard)
-		}
+	if deleteForAll {
+		params["delete_for_all"] = "1"
 	}
-	err = api.CallMethod(apiMessagesSend, params, &r)
-	return r.Response, err
+	r := RawResponse{}
+	return api.CallMethod(apiMessagesDelete, params, &r)
 }
 
-// SendPeerMessage sending a message to chat
-func (api *VkAPI) SendPeerMessage(peerID int64, msg string) (id int64, err error) {
+// PinMessage - pin message in chat by conversation_message_id
+func (api *VkAPI) PinMessage(peerID int64, conversationMessageID int64) error {
+	r := RawResponse{}
+	return api.CallMethod(apiMessagesPin, H{
+		"peer_id":                 strconv.FormatInt(peerID, 10),
+		"conversation_message_id": strconv.FormatInt(conversationMessageID, 10),
+	}, &r)
+}
+
+// UnpinMessage - unpin pinned message in chat
+func (api *VkAPI) UnpinMessage(peerID int64) error {
 	r := SimpleResponse{}
-	err = api.CallMethod(apiMessagesSend, H{
-		"peer_id":          strconv.FormatInt(peerID, 10),
-		"message":          msg,
-		"dont_parse_links": "1",
-		"random_id":        api.GetRandomID(),
+	return api.CallMethod(apiMessagesUnpin, H{
+		"peer_id": strconv.FormatInt(peerID, 10),
 	}, &r)
-	return r.Response, err
+}
+
+// GetByConversationMessageID - get messages by conversation_message_id
+func (api *VkAPI) GetByConversationMessageID(peerID int64, conversationMessageIDs []int64) ([]*Message, error) {
+	r := APIMessagesResponse{}
+	err := api.CallMethod(apiMessagesGetByConversationMsgID, H{
+		"peer_id":                  strconv.FormatInt(peerID, 10),
+		"conversation_message_ids": joinIDs(conversationMessageIDs),
+	}, &r)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]*Message, 0, len(r.Response.Items))
+	for _, m := range r.Response.Items {
+		messages = append(messages, m.Message())
+	}
+	return messages, nil
+}
+
+// SendPeerMessage sending a message to chat
+func (api *VkAPI) SendPeerMessage(peerID int64, msg string) (id int64, err error) {
+	return api.sendText("peer_id", peerID, msg)
 }
 
 // SendChatMessage sending a message to chat
 func (api *VkAPI) SendChatMessage(chatID int64, msg string) (id int64, err error) {
-	r := SimpleResponse{}
-	err = api.CallMethod(apiMessagesSend, H{
-		"chat_id":          strconv.FormatInt(chatID, 10),
-		"message":          msg,
-		"dont_parse_links": "1",
-		"random_id":        api.GetRandomID(),
-	}, &r)
-	return r.Response, err
+	return api.sendText("chat_id", chatID, msg)
 }
 
 // SendMessage sending a message to user
 func (api *VkAPI) SendMessage(userID int64, msg string) (id int64, err error) {
-	r := SimpleResponse{}
-	if msg != "" {
+	if msg == "" {
+		return 0, nil
+	}
+	return api.sendText("user_id", userID, msg)
+}
+
+// sendText - send sanitized text split to parts, returns id of last part
+func (api *VkAPI) sendText(key string, id int64, msg string) (mid int64, err error) {
+	parts := SplitMessage(SanitizeMessage(msg), MaxMessageLength)
+	for i, part := range parts {
+		r := SimpleResponse{}
 		err = api.CallMethod(apiMessagesSend, H{
-			"user_id":          strconv.FormatInt(userID, 10),
-			"message":          msg,
+			key:                strconv.FormatInt(id, 10),
+			"message":          part,
 			"dont_parse_links": "1",
 			"random_id":        api.GetRandomID(),
 		}, &r)
+		if err != nil {
+			if len(parts) > 1 {
+				err = fmt.Errorf("vkapi: send part %d of %d to %s %d: %w", i+1, len(parts), key, id, err)
+			}
+			return mid, err
+		}
+		mid = r.Response
 	}
-	return r.Response, err
+	return mid, nil
 }
 
 func NewButton(label string, payload interface{}) Button {
@@ -1,6 +1,13 @@
 package govkbot
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -197,3 +204,115 @@ func TestMessage_GetMentions(t *testing.T) {
 		t.Error("wrong mention")
 	}
 }
+
+func TestVkAPI_SendAdvancedPeerMessage(t *testing.T) {
+	SetAPI("", "test", "")
+	sent, err := API.SendPeerReply(1, Reply{Msg: "ok"})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if sent.MessageID != 532537 || sent.PeerID != 1 {
+		t.Errorf("wrong sent message: %+v", sent)
+	}
+}
+
+// sendServer - messages.send server of group token, answers by respond with peer_ids params
+func sendServer(t *testing.T, respond func(n int, params url.Values) string) (*VkAPI, *[]url.Values) {
+	var mu sync.Mutex
+	var sent []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		sent = append(sent, r.Form)
+		n := len(sent)
+		mu.Unlock()
+		w.Write([]byte(respond(n, r.Form)))
+	}))
+	t.Cleanup(srv.Close)
+	return &VkAPI{Token: "token", URL: srv.URL + "/method/", GroupID: 1}, &sent
+}
+
+func TestVkAPI_SendAdvancedPeerMessageID(t *testing.T) {
+	SetAPI("", "test", "")
+	id, err := API.SendAdvancedPeerMessage(1, Reply{Msg: "ok"})
+	if err != nil || id != 532537 {
+		t.Errorf("wrong message id: %d %v", id, err)
+	}
+}
+
+func TestVkAPI_SendAdvancedPeerMessagePeerError(t *testing.T) {
+	api, sent := sendServer(t, func(n int, params url.Values) string {
+		return `{"response": [{"peer_id": 5, "error": {"code": 901, "description": "Can't send messages"}}]}`
+	})
+	msg, err := api.SendPeerReply(5, Reply{Msg: "hi"})
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) || sendErr.Code != ErrCodeNoPermission {
+		t.Errorf("wrong peer error: %+v", err)
+	}
+	if msg.PeerID != 5 || msg.MessageID != 0 {
+		t.Errorf("wrong sent message: %+v", msg)
+	}
+	if len(*sent) != 1 || (*sent)[0].Get("peer_ids") != "5" || (*sent)[0].Get("peer_id") != "" {
+		t.Errorf("wrong send params: %+v", *sent)
+	}
+}
+
+func TestVkAPI_SendAdvancedPeerMessageParts(t *testing.T) {
+	api, sent := sendServer(t, func(n int, params url.Values) string {
+		if n == 3 {
+			return `{"response": [{"peer_id": 5, "error": {"code": 902, "description": "Privacy"}}]}`
+		}
+		return fmt.Sprintf(`{"response": [{"peer_id": 5, "message_id": %d, "conversation_message_id": %d}]}`, 10+n, n)
+	})
+	text := strings.Repeat("a", MaxMessageLength) + "\n\n" + strings.Repeat("b", 10)
+	msg, err := api.SendPeerReply(5, Reply{Msg: text, Keyboard: &Keyboard{OneTime: true}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if msg.MessageID != 12 || msg.ConversationMessageID != 2 {
+		t.Errorf("last part not returned: %+v", msg)
+	}
+	if len(*sent) != 2 || (*sent)[0].Get("keyboard") != "" || (*sent)[1].Get("keyboard") == "" ||
+		(*sent)[1].Get("message") != strings.Repeat("b", 10) {
+		t.Errorf("wrong parts: %+v", *sent)
+	}
+
+	_, err = api.SendAdvancedPeerMessage(5, Reply{Msg: text})
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) || sendErr.Code != ErrCodePrivacy || !strings.Contains(err.Error(), "part 1 of 2") {
+		t.Errorf("wrong part error: %+v", err)
+	}
+	if len(*sent) != 3 {
+		t.Errorf("parts sent after error: %d", len(*sent))
+	}
+}
+
+func TestVkAPI_EditDeletePin(t *testing.T) {
+	SetAPI("", "test", "")
+	if err := API.EditConversationMessage(1, 1, Reply{Msg: "ok"}); err != nil {
+		t.Error(err.Error())
+	}
+	if err := API.DeleteConversationMessages(1, []int64{1, 2}, true); err != nil {
+		t.Error(err.Error())
+	}
+	if err := API.PinMessage(1, 12); err != nil {
+		t.Error(err.Error())
+	}
+	if err := API.UnpinMessage(1); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestVkAPI_GetByConversationMessageID(t *testing.T) {
+	SetAPI("", "test", "")
+	messages, err := API.GetByConversationMessageID(2000000172, []int64{1078})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(messages) != 1 || messages[0].ConversationMessageID != 1078 || messages[0].Body != "test" {
+		t.Fatalf("wrong messages: %+v", messages)
+	}
+	if messages[0].ReplyMessage == nil || messages[0].ReplyMessage.UserID != -1 {
+		t.Errorf("wrong reply message: %+v", messages[0].ReplyMessage)
+	}
+}
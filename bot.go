@@ -1,75 +1,142 @@
 package govkbot
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // VKBot - bot config
 type VKBot struct {
-	msgRoutes        map[string]msgRoute
-	actionRoutes     map[string]func(*Message) string
-	cmdHandlers      map[string]func(*Message) string
-	msgHandlers      map[string]func(*Message) string
-	errorHandler     func(*Message, error)
-	LastMsg          int64
-	lastUserMessages map[int64]int64
-	lastChatMessages map[int64]int64
-	autoFriend       bool
-	IgnoreBots       bool
-	API              *VkAPI
+	msgRoutes    map[string]msgRoute
+	actionRoutes map[string]func(*Message) string
+	cmdHandlers  map[string]func(*Message) string
+	msgHandlers  map[string]func(*Message) string
+	errorHandler func(*Message, error)
+	middlewares  []Middleware
+	dialogs      map[string]*Dialog
+	stateStorage StateStorage
+	sessionStore SessionStore
+	dispatcher   *Dispatcher
+	pollOptions  PollOptions
+	throttler    *throttler
+	accessDenied string
+	chatMembers  *lruCache
+	mentionOnly  bool
+	typingAfter  time.Duration
+	scheduler    *scheduler
+	polling      *PollingServer
+	menus        *lruCache
+	logger       *slog.Logger
+	mu           sync.Mutex
+	nameMu       sync.Mutex // guards name, not held during name request
+	name         string
+	nameChecked  time.Time
+	ctx          context.Context // cancelled by Stop, stops polling
+	cancel       context.CancelFunc
+	handlerCtx   context.Context // cancelled after stopTimeout, passed to handlers
+	stopHandlers context.CancelFunc
+	stopTimeout  time.Duration
+	LastMsg      int64
+	autoFriend   bool
+	IgnoreBots   bool
+	API          *VkAPI
 }
 
 type msgRoute struct {
-	SimpleHandler func(*Message) string
-	Handler       func(*Message) Reply
+	Handler HandlerFunc
+	checks  []routeCheck
 }
 
 // NewBot - create new instance of bot
 func (api *VkAPI) NewBot() *VKBot {
+	ctx, cancel := context.WithCancel(context.Background())
+	handlerCtx, stopHandlers := context.WithCancel(context.Background())
 	return &VKBot{
-		msgRoutes:        make(map[string]msgRoute),
-		actionRoutes:     make(map[string]func(*Message) string),
-		lastUserMessages: make(map[int64]int64),
-		lastChatMessages: make(map[int64]int64),
-		API:              api,
+		pollOptions:  DefaultPollOptions(),
+		accessDenied: DefaultAccessDeniedMessage,
+		chatMembers:  newLRUCache(chatMembersCacheSize),
+		ctx:          ctx,
+		cancel:       cancel,
+		handlerCtx:   handlerCtx,
+		stopHandlers: stopHandlers,
+		stopTimeout:  DefaultStopTimeout,
+		msgRoutes:    make(map[string]msgRoute),
+		actionRoutes: make(map[string]func(*Message) string),
+		dialogs:      make(map[string]*Dialog),
+		stateStorage: NewMemoryStateStorage(),
+		sessionStore: NewMemorySessionStore(DefaultSessionSize),
+		scheduler:    newScheduler(NewMemoryJobStore()),
+		menus:        newLRUCache(menuCacheSize),
+		API:          api,
 	}
 }
 
 // ListenUser - listen User VK API (deprecated)
 func (bot *VKBot) ListenUser(api *VkAPI) error {
-	poller := NewUserLongPollServer(false, longPollVersion, API.RequestInterval)
+	poller := NewUserLongPollServer(false, longPollVersion, api.RequestInterval)
+	poller.API = api
+	poller.Wait = bot.pollOptions.Wait
 	go bot.friendReceiver()
+	return bot.listen(poller)
+}
 
-	c := time.Tick(3 * time.Second)
-	for range c {
-		bot.MainRoute(poller)
-	}
-	return nil
+// ListenPolling - listen by polling unread conversations. Use if long poll is blocked
+func (bot *VKBot) ListenPolling(api *VkAPI, interval time.Duration) error {
+	return bot.ListenPollingServer(NewPollingServer(api, interval))
+}
+
+// ListenPollingServer - listen by configured polling server
+func (bot *VKBot) ListenPollingServer(server *PollingServer) error {
+	server.done = bot.ctx.Done()
+	return bot.listen(server)
 }
 
 // ListenGroup - listen group VK API
 func (bot *VKBot) ListenGroup(api *VkAPI) error {
-	poller := NewGroupLongPollServer(API.RequestInterval)
-	c := time.Tick(3 * time.Second)
-	for range c {
-		bot.MainRoute(poller)
+	poller := NewGroupLongPollServer(api.RequestInterval)
+	poller.API = api
+	poller.Wait = bot.pollOptions.Wait
+	return bot.listen(poller)
+}
+
+// Handle - add substr message handler with context.
+// Handler can add replies by c.Reply and return error to pass it to error handler
+func (bot *VKBot) Handle(command string, handler HandlerFunc, options ...RouteOption) {
+	route := msgRoute{Handler: handler}
+	for _, option := range options {
+		option(&route)
 	}
-	return nil
+	bot.msgRoutes[command] = route
+}
+
+// Use - add middlewares, called before each message handler in order of adding
+func (bot *VKBot) Use(middlewares ...Middleware) {
+	bot.middlewares = append(bot.middlewares, middlewares...)
 }
 
 // HandleMessage - add substr message handler.
 // Function must return string to reply or "" (if no reply)
-func (bot *VKBot) HandleMessage(command string, handler func(*Message) string) {
-	bot.msgRoutes[command] = msgRoute{SimpleHandler: handler}
+func (bot *VKBot) HandleMessage(command string, handler func(*Message) string, options ...RouteOption) {
+	bot.Handle(command, func(c *Context) error {
+		c.Reply(handler(c.Message))
+		return nil
+	}, options...)
 }
 
 // HandleAdvancedMessage - add substr message handler.
 // Function must return string to reply or "" (if no reply)
-func (bot *VKBot) HandleAdvancedMessage(command string, handler func(*Message) Reply) {
-	bot.msgRoutes[command] = msgRoute{Handler: handler}
+func (bot *VKBot) HandleAdvancedMessage(command string, handler func(*Message) Reply, options ...RouteOption) {
+	bot.Handle(command, func(c *Context) error {
+		c.Respond(handler(c.Message))
+		return nil
+	}, options...)
 }
 
 // HandleAction - add action handler.
@@ -83,6 +150,80 @@ func (bot *VKBot) HandleError(handler func(*Message, error)) {
 	bot.errorHandler = handler
 }
 
+func (bot *VKBot) sendError(msg *Message, err error) {
+	if bot.errorHandler != nil {
+		bot.errorHandler(msg, err)
+	} else {
+		logger := bot.log()
+		if msg != nil {
+			logger = logger.With("peer_id", msg.PeerID, "message_id", msg.ID)
+		}
+		logger.Error("vkbot error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// SetWorkers - process messages in parallel by workers count.
+// Messages of one peer processed in order, queueSize limits waiting messages of each worker.
+// Waits for messages of previous workers, so it must not be called from message handler
+func (bot *VKBot) SetWorkers(workers int, queueSize int) {
+	dispatcher := NewDispatcher(workers, queueSize, func(m *Message) {
+		bot.ProcessMessage(bot.handlerCtx, m)
+	})
+	bot.mu.Lock()
+	old := bot.dispatcher
+	bot.dispatcher = dispatcher
+	bot.mu.Unlock()
+	if old != nil {
+		old.Stop()
+	}
+}
+
+// getDispatcher - current workers dispatcher, nil if messages processed in poll loop
+func (bot *VKBot) getDispatcher() *Dispatcher {
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+	return bot.dispatcher
+}
+
+// DefaultStopTimeout - time for messages in progress to finish after Stop, then handler contexts are cancelled
+const DefaultStopTimeout = 10 * time.Second
+
+// SetStopTimeout - set time for messages in progress to finish after Stop
+func (bot *VKBot) SetStopTimeout(timeout time.Duration) {
+	bot.stopTimeout = timeout
+}
+
+// Stop - stop listening and workers. Queued messages are processed, c.Ctx of handlers
+// is cancelled if they are not finished in stop timeout. Listen returns after current long poll request.
+// With workers Stop waits for them, so call it from message handler in goroutine: go bot.Stop()
+func (bot *VKBot) Stop() {
+	bot.cancel()
+	dispatcher := bot.getDispatcher()
+	if dispatcher == nil {
+		time.AfterFunc(bot.stopTimeout, bot.stopHandlers)
+		return
+	}
+	drained := make(chan struct{})
+	go func() {
+		dispatcher.Stop()
+		close(drained)
+	}()
+	timer := time.NewTimer(bot.stopTimeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+	case <-timer.C:
+		bot.stopHandlers()
+		<-drained
+	}
+	bot.stopHandlers()
+}
+
+func (bot *VKBot) stopped() bool {
+	return bot.ctx.Err() != nil
+}
+
 // SetAutoFriend - auto add friends
 func (bot *VKBot) SetAutoFriend(af bool) {
 	bot.autoFriend = af
@@ -93,46 +234,33 @@ func (bot *VKBot) SetIgnoreBots(ignore bool) {
 	bot.IgnoreBots = ignore
 }
 
-// GetMessages - request unread messages from VK (more than 200)
+// GetMessages - request new messages of unread conversations (polling fallback)
 func (bot *VKBot) GetMessages() ([]*Message, error) {
-	var allMessages []*Message
-	lastMsg := bot.LastMsg
-	offset := 0
-	var err error
-	var messages *Messages
-	for {
-		messages, err = API.GetMessages(API.MessagesCount, offset)
-		if len(messages.Items) > 0 {
-			if messages.Items[0].ID > lastMsg {
-				lastMsg = messages.Items[0].ID
-			}
-		}
-		allMessages = append(allMessages, messages.Items...)
-		if bot.LastMsg > 0 {
-			if len(messages.Items) > 0 {
-				if messages.Items[len(messages.Items)-1].ID <= bot.LastMsg {
-					bot.LastMsg = lastMsg
-					break
-				}
-			} else {
-				break
-			}
-			offset += API.MessagesCount
-		} else {
-			bot.LastMsg = lastMsg
-			break
-		}
+	bot.mu.Lock()
+	if bot.polling == nil {
+		bot.polling = NewPollingServer(bot.API, 0)
 	}
-	if offset > 0 {
-		API.NotifyAdmin("many messages in interval. offset: " + strconv.Itoa(offset))
+	polling := bot.polling
+	bot.mu.Unlock()
+	messages, err := polling.GetMessages()
+	bot.mu.Lock()
+	for _, m := range messages {
+		if m.ID > bot.LastMsg {
+			bot.LastMsg = m.ID
+		}
 	}
-	return allMessages, err
+	bot.mu.Unlock()
+	return messages, err
 }
 
 // RouteAction routes an action
 func (bot *VKBot) RouteAction(m *Message) (replies []string, err error) {
 	if m.Action != "" {
-		debugPrint("route action: %+v\n", m.Action)
+		bot.log().Debug("route action", "peer_id", m.PeerID, "action", m.Action)
+		if strings.HasPrefix(m.Action, "chat_") {
+			bot.API.InvalidateChat(m.peer())
+			bot.chatMembers.Delete(strconv.FormatInt(m.peer(), 10))
+		}
 		for k, v := range bot.actionRoutes {
 			if m.Action == k {
 				msg := v(m)
@@ -147,10 +275,12 @@ func (bot *VKBot) RouteAction(m *Message) (replies []string, err error) {
 
 // RouteMessage routes single message
 func (bot *VKBot) RouteMessage(m *Message) (replies []Reply, err error) {
-	message := strings.TrimSpace(strings.ToLower(m.Body))
-	if HasPrefix(message, "/ ") {
-		message = "/" + TrimPrefix(message, "/ ")
-	}
+	return bot.RouteMessageContext(context.Background(), m)
+}
+
+// RouteMessageContext routes single message with context
+func (bot *VKBot) RouteMessageContext(ctx context.Context, m *Message) (replies []Reply, err error) {
+	message := normalizeCommand(m.Body)
 	if m.Action != "" {
 		actionReplies, err := bot.RouteAction(m)
 		for _, r := range actionReplies {
@@ -158,75 +288,147 @@ func (bot *VKBot) RouteMessage(m *Message) (replies []Reply, err error) {
 		}
 		return replies, err
 	}
-	for k, v := range bot.msgRoutes {
-		if HasPrefix(message, k) {
-			if v.Handler != nil {
-				reply := v.Handler(m)
-				if reply.Msg != "" || reply.Keyboard != nil {
-					replies = append(replies, reply)
-				}
-			} else {
-				msg := v.SimpleHandler(m)
-				if msg != "" {
-					replies = append(replies, Reply{Msg: msg})
-				}
+	d, state, err := bot.activeDialog(m)
+	if err != nil {
+		return nil, err
+	}
+	// numbers are dialog answers while dialog is active
+	menuChoice := d == nil && bot.applyMenuChoice(m)
+	if menuChoice {
+		message = normalizeCommand(m.Body)
+	}
+	if bot.mentionOnly && m.IsChat() && d == nil && !menuChoice {
+		ok, body := bot.addressed(m)
+		if !ok {
+			return nil, nil
+		}
+		m.Body = body
+		message = normalizeCommand(body)
+	}
+	commands := bot.matchRoutes(message)
+	if bot.throttler != nil && (d != nil || len(commands) > 0) {
+		// dialog answers are limited by user and peer limits only
+		throttled := commands
+		if d != nil {
+			throttled = nil
+		}
+		if ok, throttleReplies := bot.throttler.check(m, throttled); !ok {
+			bot.log().Debug("throttled", "peer_id", m.PeerID, "user_id", m.UserID)
+			return throttleReplies, nil
+		}
+	}
+	if d != nil {
+		if ok, dialogReplies, err := bot.routeDialog(ctx, m, message, d, state); ok || err != nil {
+			return dialogReplies, err
+		}
+	}
+	denied := false
+	for _, k := range commands {
+		route := bot.msgRoutes[k]
+		c := bot.newContext(ctx, m)
+		c.Command = k
+		c.Args = strings.Fields(TrimPrefix(message, k))
+		allowed, cerr := route.allowed(c)
+		if cerr != nil {
+			err = cerr
+			continue
+		}
+		if !allowed {
+			denied = true
+			continue
+		}
+		if herr := bot.wrapHandler(route.Handler)(c); herr != nil {
+			err = herr
+		}
+		replies = append(replies, c.Replies()...)
+	}
+	if denied && bot.accessDenied != "" {
+		replies = append(replies, Reply{Msg: bot.accessDenied})
+	}
+	return replies, err
+}
 
-			}
+// matchRoutes - returns sorted commands of routes matched message
+func (bot *VKBot) matchRoutes(message string) (commands []string) {
+	for k := range bot.msgRoutes {
+		if HasPrefix(message, k) {
+			commands = append(commands, k)
 		}
 	}
-	return replies, nil
+	sort.Strings(commands)
+	return commands
+}
+
+// normalizeCommand - trim message and join "/ command" to "/command"
+func normalizeCommand(body string) string {
+	message := strings.TrimSpace(body)
+	if HasPrefix(message, "/ ") {
+		message = "/" + TrimPrefix(message, "/ ")
+	}
+	return message
+}
+
+func (bot *VKBot) wrapHandler(handler HandlerFunc) HandlerFunc {
+	for i := len(bot.middlewares) - 1; i >= 0; i-- {
+		handler = bot.middlewares[i](handler)
+	}
+	return handler
 }
 
 // RouteMessages routes inbound messages
 func (bot *VKBot) RouteMessages(messages []*Message) (result map[*Message][]Reply) {
 	result = make(map[*Message][]Reply)
 	for _, m := range messages {
-		if m.ReadState == 0 {
-			if bot.IgnoreBots && m.UserID < 0 {
-				continue
-			}
-			replies, err := bot.RouteMessage(m)
-			if err != nil {
-				sendError(m, err)
-			}
-			if len(replies) > 0 {
-				result[m] = replies
-			}
+		if !bot.accept(m) {
+			continue
+		}
+		replies, err := bot.RouteMessage(m)
+		if err != nil {
+			bot.sendError(m, err)
+		}
+		if len(replies) > 0 {
+			result[m] = replies
 		}
 	}
 	return result
 }
 
-// MainRoute - main router func. Working cycle Listen.
-func (bot *VKBot) MainRoute(poller LongPollServer) {
-	messages, err := poller.GetLongPollMessages()
+func (bot *VKBot) accept(m *Message) bool {
+	return !bot.IgnoreBots || m.UserID >= 0
+}
+
+// ProcessMessage - route message and send replies
+func (bot *VKBot) ProcessMessage(ctx context.Context, m *Message) {
+	stopTyping := bot.startTyping(m)
+	replies, err := bot.RouteMessageContext(ctx, m)
+	stopTyping()
 	if err != nil {
-		sendError(nil, err)
+		bot.sendError(m, err)
 	}
-	debugPrint("inbox: %+v\n", messages)
-	replies := bot.RouteMessages(messages)
-	for m, msgs := range replies {
-		for _, reply := range msgs {
-			debugPrint("outbox: ", reply.Msg)
-			if reply.Msg != "" || reply.Keyboard != nil {
-				_, err = bot.Reply(m, reply)
-				if err != nil {
-					log.Printf("Error sending message: '%+v'\n", reply)
-					sendError(m, err)
-					_, err = bot.Reply(m, Reply{Msg: "Cant send message, maybe wrong/china letters?"})
-					if err != nil {
-						sendError(m, err)
-					}
-				}
+	for _, reply := range replies {
+		if reply.Msg != "" || reply.Keyboard != nil || reply.Template != nil {
+			id, err := bot.Reply(m, reply)
+			bot.log().Debug("outbox", "peer_id", m.PeerID, "message_id", id, "error", err)
+			if err != nil {
+				bot.sendError(m, fmt.Errorf("vkbot: send reply to peer %d: %w", m.PeerID, err))
 			}
 		}
 	}
 }
 
-// Reply - reply message
+// MainRoute - main router func. Working cycle Listen.
+func (bot *VKBot) MainRoute(poller LongPollServer) {
+	if _, err := bot.Poll(poller); err != nil {
+		bot.sendError(nil, err)
+	}
+}
+
+// Reply - reply message. Keyboard not supported by user client is sent as numbered text menu
 func (bot *VKBot) Reply(m *Message, reply Reply) (id int64, err error) {
+	reply = bot.keyboardFallback(m, reply)
 	if m.PeerID != 0 {
-		return bot.API.SendAdvancedPeerMessage(m.PeerID, reply)
+		sent, err := bot.API.SendPeerReply(m.PeerID, reply)
+		return sent.MessageID, err
 	}
 	if m.ChatID != 0 {
 		return bot.API.SendChatMessage(m.ChatID, reply.Msg)
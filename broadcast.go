@@ -0,0 +1,253 @@
+package govkbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Broadcast VK API error codes
+const (
+	ErrCodeTooManyRequests = 6
+	ErrCodeBlacklisted     = 900 // user added group to blacklist
+	ErrCodeNoPermission    = 901 // user not allowed messages from group
+	ErrCodePrivacy         = 902 // user privacy settings
+)
+
+// BroadcastBatchSize - max peers in one messages.send call
+const BroadcastBatchSize = 100
+
+// broadcastRetries - retries of batch on too many requests error
+const broadcastRetries = 3
+
+// PeerIterator - source of broadcast peer ids
+type PeerIterator interface {
+	Next() bool
+	Item() int64
+	Err() error
+}
+
+type peerSlice struct {
+	ids []int64
+	i   int
+}
+
+// PeerSlice - iterator over peer ids slice
+func PeerSlice(ids []int64) PeerIterator {
+	return &peerSlice{ids: ids, i: -1}
+}
+
+func (p *peerSlice) Next() bool {
+	p.i++
+	return p.i < len(p.ids)
+}
+
+func (p *peerSlice) Item() int64 {
+	return p.ids[p.i]
+}
+
+func (p *peerSlice) Err() error {
+	return nil
+}
+
+// BroadcastOptions - broadcast options
+type BroadcastOptions struct {
+	BatchSize  int                     // peers per call, max and default BroadcastBatchSize
+	Interval   time.Duration           // pause between batches
+	Checkpoint string                  // file to save progress. Broadcast resumes from it after restart
+	OnProgress func(BroadcastProgress) // called after each batch
+}
+
+// BroadcastFailure - peer which not received message
+type BroadcastFailure struct {
+	PeerID      int64  `json:"peer_id"`
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+}
+
+// BroadcastProgress - broadcast state, saved to checkpoint
+type BroadcastProgress struct {
+	Processed int                `json:"processed"` // peers taken from iterator
+	Sent      int                `json:"sent"`
+	Failed    []BroadcastFailure `json:"failed"`
+	Done      bool               `json:"done"`
+
+	// Long message parts already sent to current batch and its failures, last ones in Failed.
+	// Resumed broadcast continues batch from next part
+	Part       int `json:"part,omitempty"`
+	PartFailed int `json:"part_failed,omitempty"`
+}
+
+// Broadcast - send reply to all peers by batches with peer_ids (group tokens) or one by one (user tokens).
+// Peers which can't receive messages are collected to Failed, other errors stop broadcast.
+// With checkpoint broadcast can be restarted with same peers and options and continues from last sent batch
+// or part of long message, finished broadcast is not sent again until checkpoint file removed
+func (bot *VKBot) Broadcast(ctx context.Context, peers PeerIterator, reply Reply, options BroadcastOptions) (BroadcastProgress, error) {
+	if options.BatchSize <= 0 || options.BatchSize > BroadcastBatchSize {
+		options.BatchSize = BroadcastBatchSize
+	}
+	if !bot.API.IsGroup() {
+		options.BatchSize = 1
+	}
+	progress, err := loadBroadcastProgress(options.Checkpoint)
+	if err != nil || progress.Done {
+		return progress, err
+	}
+	for skip := 0; skip < progress.Processed && peers.Next(); skip++ {
+	}
+
+	batch := make([]int64, 0, options.BatchSize)
+	for {
+		batch = batch[:0]
+		for len(batch) < options.BatchSize && peers.Next() {
+			batch = append(batch, peers.Item())
+		}
+		if err = peers.Err(); err != nil {
+			return progress, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		if err = ctx.Err(); err != nil {
+			return progress, err
+		}
+		failed, err := bot.broadcastBatch(ctx, batch, reply, &progress, options.Checkpoint)
+		if err != nil {
+			return progress, err
+		}
+		progress.Processed += len(batch)
+		progress.Sent += len(batch) - failed
+		progress.Part, progress.PartFailed = 0, 0
+		if err = saveBroadcastProgress(options.Checkpoint, progress); err != nil {
+			return progress, err
+		}
+		if options.OnProgress != nil {
+			options.OnProgress(progress)
+		}
+		if options.Interval > 0 {
+			select {
+			case <-ctx.Done():
+				return progress, ctx.Err()
+			case <-time.After(options.Interval):
+			}
+		}
+	}
+	progress.Done = true
+	err = saveBroadcastProgress(options.Checkpoint, progress)
+	if options.OnProgress != nil {
+		options.OnProgress(progress)
+	}
+	return progress, err
+}
+
+// broadcastBatch - send parts of reply to peers starting from progress.Part. Peers failed on part skip next parts.
+// Failures are added to progress, it is saved after each part except last one. Returns batch failures count
+func (bot *VKBot) broadcastBatch(ctx context.Context, peers []int64, reply Reply, progress *BroadcastProgress, checkpoint string) (int, error) {
+	extra, err := replyParams(reply)
+	if err != nil {
+		return 0, err
+	}
+	failed := make(map[int64]bool)
+	for _, f := range progress.Failed[len(progress.Failed)-progress.PartFailed:] {
+		failed[f.PeerID] = true
+	}
+	parts := SplitMessage(SanitizeMessage(reply.Msg), MaxMessageLength)
+	for i := progress.Part; i < len(parts); i++ {
+		active := make([]int64, 0, len(peers))
+		for _, id := range peers {
+			if !failed[id] {
+				active = append(active, id)
+			}
+		}
+		if len(active) == 0 {
+			break
+		}
+		params := H{
+			"message":          parts[i],
+			"dont_parse_links": "1",
+			"random_id":        bot.API.GetRandomID(),
+		}
+		if bot.API.IsGroup() {
+			params["peer_ids"] = joinIDs(active)
+		} else {
+			params["peer_id"] = strconv.FormatInt(active[0], 10)
+		}
+		if i == len(parts)-1 {
+			for k, v := range extra {
+				params[k] = v
+			}
+		}
+		messages, err := bot.broadcastSend(ctx, params)
+		var vkErr *VKError
+		if err != nil && len(active) == 1 && errors.As(err, &vkErr) && isBroadcastFailure(vkErr.ErrorCode) {
+			messages = []SentMessage{{PeerID: active[0], Error: &SendError{Code: vkErr.ErrorCode, Description: vkErr.ErrorMsg}}}
+			err = nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		for _, sent := range messages {
+			if sent.Error != nil && !failed[sent.PeerID] {
+				failed[sent.PeerID] = true
+				progress.PartFailed++
+				progress.Failed = append(progress.Failed, BroadcastFailure{
+					PeerID:      sent.PeerID,
+					Code:        sent.Error.Code,
+					Description: sent.Error.Description,
+				})
+			}
+		}
+		if i < len(parts)-1 {
+			progress.Part = i + 1
+			if err = saveBroadcastProgress(checkpoint, *progress); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return progress.PartFailed, nil
+}
+
+// broadcastSend - send with retries on too many requests error
+func (bot *VKBot) broadcastSend(ctx context.Context, params H) ([]SentMessage, error) {
+	for retry := 0; ; retry++ {
+		messages, err := bot.API.send(params)
+		var vkErr *VKError
+		if err == nil || retry >= broadcastRetries || !errors.As(err, &vkErr) || vkErr.ErrorCode != ErrCodeTooManyRequests {
+			return messages, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second << retry):
+		}
+	}
+}
+
+func isBroadcastFailure(code int) bool {
+	return code == ErrCodeBlacklisted || code == ErrCodeNoPermission || code == ErrCodePrivacy
+}
+
+func loadBroadcastProgress(path string) (progress BroadcastProgress, err error) {
+	if path == "" {
+		return progress, nil
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return progress, nil
+	}
+	if err != nil || len(content) == 0 {
+		return progress, err
+	}
+	err = json.Unmarshal(content, &progress)
+	return progress, err
+}
+
+func saveBroadcastProgress(path string, progress BroadcastProgress) error {
+	if path == "" {
+		return nil
+	}
+	return writeJSONFile(path, progress)
+}
@@ -0,0 +1,105 @@
+package govkbot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testPeers(n int) []int64 {
+	peers := make([]int64, n)
+	for i := range peers {
+		peers[i] = int64(i + 1)
+	}
+	return peers
+}
+
+func TestVKBot_Broadcast(t *testing.T) {
+	SetAPI("", "test", "")
+	interval := API.RequestInterval
+	API.RequestInterval = 0
+	t.Cleanup(func() { API.RequestInterval = interval })
+	bot := API.NewBot()
+	calls := 0
+	progress, err := bot.Broadcast(context.Background(), PeerSlice(testPeers(250)), Reply{Msg: "news"}, BroadcastOptions{
+		OnProgress: func(p BroadcastProgress) { calls++ },
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !progress.Done || progress.Processed != 250 || progress.Sent != 250 || len(progress.Failed) != 0 {
+		t.Errorf("wrong progress: %+v", progress)
+	}
+	if calls != 4 {
+		t.Errorf("wrong progress calls: %d", calls)
+	}
+}
+
+func TestVKBot_BroadcastCheckpoint(t *testing.T) {
+	SetAPI("", "test", "")
+	interval := API.RequestInterval
+	API.RequestInterval = 0
+	t.Cleanup(func() { API.RequestInterval = interval })
+	bot := API.NewBot()
+	path := filepath.Join(t.TempDir(), "broadcast.json")
+	if err := saveBroadcastProgress(path, BroadcastProgress{Processed: 200, Sent: 199,
+		Failed: []BroadcastFailure{{PeerID: 5, Code: ErrCodeNoPermission}}}); err != nil {
+		t.Fatal(err.Error())
+	}
+	options := BroadcastOptions{Checkpoint: path}
+	progress, err := bot.Broadcast(context.Background(), PeerSlice(testPeers(250)), Reply{Msg: "news"}, options)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !progress.Done || progress.Processed != 250 || progress.Sent != 249 || len(progress.Failed) != 1 {
+		t.Errorf("wrong progress: %+v", progress)
+	}
+	saved, err := loadBroadcastProgress(path)
+	if err != nil || !saved.Done {
+		t.Errorf("wrong checkpoint: %+v %+v", saved, err)
+	}
+
+	progress, err = bot.Broadcast(context.Background(), PeerSlice(testPeers(250)), Reply{Msg: "news"}, options)
+	if err != nil || progress.Sent != 249 {
+		t.Errorf("finished broadcast sent again: %+v %+v", progress, err)
+	}
+}
+
+func TestVKBot_BroadcastResumeParts(t *testing.T) {
+	var parts []string
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if len(parts) == 1 && fail {
+			fail = false
+			w.Write([]byte(`{"error":{"error_code":10,"error_msg":"Internal server error"}}`))
+			return
+		}
+		parts = append(parts, r.Form.Get("message"))
+		w.Write([]byte(`{"response":[{"peer_id":1,"message_id":1},{"peer_id":2,"message_id":2}]}`))
+	}))
+	defer srv.Close()
+	bot := (&VkAPI{Token: "token", URL: srv.URL + "/method/", GroupID: 1}).NewBot()
+	options := BroadcastOptions{Checkpoint: filepath.Join(t.TempDir(), "broadcast.json")}
+	reply := Reply{Msg: strings.Repeat("a", MaxMessageLength) + " second"}
+
+	if _, err := bot.Broadcast(context.Background(), PeerSlice(testPeers(2)), reply, options); err == nil {
+		t.Fatal("second part error not returned")
+	}
+	saved, err := loadBroadcastProgress(options.Checkpoint)
+	if err != nil || saved.Processed != 0 || saved.Part != 1 {
+		t.Fatalf("first part not saved: %+v %v", saved, err)
+	}
+
+	// resumed broadcast sends only second part
+	progress, err := bot.Broadcast(context.Background(), PeerSlice(testPeers(2)), reply, options)
+	if err != nil || !progress.Done || progress.Sent != 2 || progress.Part != 0 {
+		t.Fatalf("wrong progress: %+v %v", progress, err)
+	}
+	if len(parts) != 2 || parts[1] != "second" {
+		t.Errorf("wrong sent parts: %q", parts)
+	}
+}
@@ -0,0 +1,167 @@
+package govkbot
+
+import (
+	"container/list"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lruCache - size limited cache with per entry TTL. Least recently used entries evicted first
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// newLRUCache - create cache. Size <= 0 means unlimited
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+// Set - set value. Zero ttl means no expiration
+func (c *lruCache) Set(key string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.value = value
+		entry.expires = expires
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, value: value, expires: expires})
+	if c.size > 0 && c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+	}
+}
+
+func (c *lruCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// DeletePrefix - delete all entries with key prefix
+func (c *lruCache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *lruCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *lruCache) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry).key)
+}
+
+// Recommended API cache settings
+const (
+	DefaultCacheTTL  = 5 * time.Minute
+	DefaultCacheSize = 10000
+)
+
+// SetCache - enable cache of API lookups (users, screen names, chat info and members, current group) for ttl.
+// Cache is disabled by default, zero ttl disables it. Zero size is DefaultCacheSize
+func (api *VkAPI) SetCache(ttl time.Duration, size int) {
+	if ttl <= 0 {
+		api.cache = nil
+		api.cacheTTL = 0
+		return
+	}
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	api.cache = newLRUCache(size)
+	api.cacheTTL = ttl
+}
+
+func (api *VkAPI) cacheGet(key string) (interface{}, bool) {
+	if api.cache == nil {
+		return nil, false
+	}
+	return api.cache.Get(key)
+}
+
+func (api *VkAPI) cacheSet(key string, value interface{}) {
+	if api.cache != nil {
+		api.cache.Set(key, value, api.cacheTTL)
+	}
+}
+
+// InvalidateChat - remove cached chat info and members of peer
+func (api *VkAPI) InvalidateChat(peerID int64) {
+	if api.cache != nil {
+		api.cache.DeletePrefix(chatCacheKey(peerID))
+	}
+}
+
+// chatCacheKey - chat cache key prefix by peer id
+func chatCacheKey(peerID int64) string {
+	return "chat:" + strconv.FormatInt(peerID, 10) + ":"
+}
+
+// chatArgCacheKey - cache key prefix of GetChatInfo and GetChatUsers argument,
+// it is peer id for groups and chat id for users
+func (api *VkAPI) chatArgCacheKey(chatID int64) string {
+	if !api.IsGroup() && chatID > 0 && chatID < ChatPrefix {
+		chatID += ChatPrefix
+	}
+	return chatCacheKey(chatID)
+}
+
+// cloneUsers - copy users, cached users must not be changed by callers
+func cloneUsers(users []*User) []*User {
+	if users == nil {
+		return nil
+	}
+	result := make([]*User, len(users))
+	for i, u := range users {
+		c := *u
+		result[i] = &c
+	}
+	return result
+}
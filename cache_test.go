@@ -0,0 +1,110 @@
+package govkbot
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(2)
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+	c.Get("a")
+	c.Set("c", 3, 0)
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry not evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v.(int) != 1 {
+		t.Error(wrongValueReturned)
+	}
+	c.Set("d", 4, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, ok := c.Get("d"); ok {
+		t.Error("expired entry returned")
+	}
+}
+
+func TestVkAPI_ChatCache(t *testing.T) {
+	SetAPI("", "test", "")
+	API.SetCache(time.Minute, 100)
+	defer API.SetCache(0, 0)
+	users, err := API.GetChatUsers(ChatPrefix + 1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(users) == 0 {
+		t.Fatal(wrongValueReturned)
+	}
+	users[0].FirstName = "changed"
+	if _, ok := API.cacheGet(chatCacheKey(ChatPrefix+1) + "users"); !ok {
+		t.Fatal("chat users not cached")
+	}
+	API.InvalidateChat(1)
+	if _, ok := API.cacheGet(chatCacheKey(ChatPrefix+1) + "users"); !ok {
+		t.Fatal("chat invalidated by user peer with same local id")
+	}
+	cached, _ := API.GetChatUsers(ChatPrefix + 1)
+	if cached[0].FirstName == "changed" {
+		t.Error("cached users changed by caller")
+	}
+	if _, err = API.GetChatInfo(ChatPrefix + 1); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	bot := API.NewBot()
+	bot.RouteMessage(&Message{PeerID: ChatPrefix + 1, UserID: 1, Action: "chat_invite_user", ActionMID: 2})
+	if _, ok := API.cacheGet(chatCacheKey(ChatPrefix+1) + "users"); ok {
+		t.Error("chat users not invalidated")
+	}
+	if _, ok := API.cacheGet(chatCacheKey(ChatPrefix+1) + "info"); ok {
+		t.Error("chat info not invalidated")
+	}
+
+	API.SetCache(0, 0)
+	if _, err = API.GetChatUsers(ChatPrefix + 1); err != nil {
+		t.Error(err.Error())
+	}
+	if _, ok := API.cacheGet(chatCacheKey(ChatPrefix+1) + "users"); ok {
+		t.Error("disabled cache used")
+	}
+}
+
+func TestVkAPI_CacheDisabledByDefault(t *testing.T) {
+	api := newAPI()
+	api.URL = "test"
+	if _, err := api.GetChatUsers(ChatPrefix + 1); err != nil {
+		t.Fatal(err.Error())
+	}
+	if api.cache != nil {
+		t.Error("cache enabled by default")
+	}
+}
+
+func TestGroupLongPollServer_ParseMessageAction(t *testing.T) {
+	server := NewGroupLongPollServer(0)
+	msg, err := server.ParseMessage(map[string]interface{}{
+		"id":      json.Number("0"),
+		"text":    "",
+		"from_id": json.Number("1"),
+		"peer_id": json.Number("2000000001"),
+		"action":  map[string]interface{}{"type": "chat_kick_user", "member_id": json.Number("2")},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if msg.Action != "chat_kick_user" || msg.ActionMID != 2 {
+		t.Errorf("wrong action: %+v", msg)
+	}
+}
+
+func TestVkAPI_ChatArgCacheKey(t *testing.T) {
+	group := &VkAPI{GroupID: 1}
+	if group.chatArgCacheKey(5) != chatCacheKey(5) {
+		t.Error("group peer id changed")
+	}
+	user := &VkAPI{Token: "token", UID: 1}
+	if user.chatArgCacheKey(5) != chatCacheKey(ChatPrefix+5) || user.chatArgCacheKey(ChatPrefix+5) != chatCacheKey(ChatPrefix+5) {
+		t.Error("user chat id not converted to peer id")
+	}
+}
@@ -0,0 +1,127 @@
+package govkbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Carousel limits
+const (
+	CarouselMaxElements    = 10
+	CarouselMaxButtons     = 3
+	CarouselMaxTitle       = 80
+	CarouselMaxDescription = 80
+)
+
+// Carousel element actions
+const (
+	CarouselOpenLink  = "open_link"
+	CarouselOpenPhoto = "open_photo"
+)
+
+// Carousel - template message with horizontal list of elements
+type Carousel struct {
+	Type     string            `json:"type"`
+	Elements []CarouselElement `json:"elements"`
+}
+
+// CarouselElement - carousel element. Needs title or photo, all elements must have same fields and buttons count
+type CarouselElement struct {
+	Title       string          `json:"title,omitempty"`
+	Description string          `json:"description,omitempty"`
+	PhotoID     string          `json:"photo_id,omitempty"` // like -123_456
+	Action      *CarouselAction `json:"action,omitempty"`
+	Buttons     []Button        `json:"buttons"`
+}
+
+// CarouselAction - action on element click
+type CarouselAction struct {
+	Type string `json:"type"`
+	Link string `json:"link,omitempty"`
+}
+
+// NewCarousel - create carousel
+func NewCarousel(elements ...CarouselElement) *Carousel {
+	return &Carousel{Type: "carousel", Elements: elements}
+}
+
+// Validate - check carousel by VK rules
+func (c *Carousel) Validate() error {
+	if len(c.Elements) == 0 || len(c.Elements) > CarouselMaxElements {
+		return fmt.Errorf("vkapi: carousel must have 1-%d elements, has %d", CarouselMaxElements, len(c.Elements))
+	}
+	first := c.Elements[0]
+	for i, e := range c.Elements {
+		if e.Title == "" && e.PhotoID == "" {
+			return fmt.Errorf("vkapi: carousel element %d needs title or photo", i+1)
+		}
+		if utf8.RuneCountInString(e.Title) > CarouselMaxTitle {
+			return fmt.Errorf("vkapi: carousel element %d title longer than %d", i+1, CarouselMaxTitle)
+		}
+		if utf8.RuneCountInString(e.Description) > CarouselMaxDescription {
+			return fmt.Errorf("vkapi: carousel element %d description longer than %d", i+1, CarouselMaxDescription)
+		}
+		if len(e.Buttons) == 0 || len(e.Buttons) > CarouselMaxButtons {
+			return fmt.Errorf("vkapi: carousel element %d must have 1-%d buttons", i+1, CarouselMaxButtons)
+		}
+		if (e.Title == "") != (first.Title == "") || (e.PhotoID == "") != (first.PhotoID == "") ||
+			(e.Description == "") != (first.Description == "") || len(e.Buttons) != len(first.Buttons) {
+			return fmt.Errorf("vkapi: carousel element %d differs from first element", i+1)
+		}
+		if e.Action != nil && e.Action.Type != CarouselOpenLink && e.Action.Type != CarouselOpenPhoto {
+			return fmt.Errorf("vkapi: carousel element %d has wrong action %s", i+1, e.Action.Type)
+		}
+		if e.Action != nil && e.Action.Type == CarouselOpenLink && e.Action.Link == "" {
+			return fmt.Errorf("vkapi: carousel element %d open_link action needs link", i+1)
+		}
+	}
+	return nil
+}
+
+// JSON - returns validated carousel encoded for VK API. Carousel is not changed, it can be shared template
+func (c *Carousel) JSON() (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+	template := *c
+	if template.Type == "" {
+		template.Type = "carousel"
+	}
+	b, err := json.Marshal(template)
+	return string(b), err
+}
+
+// Keyboard - carousel as inline keyboard with element buttons, used if client can't show carousel.
+// Big carousel exceeds inline keyboard limits, such keyboard is sent as numbered text menu
+func (c *Carousel) Keyboard() *Keyboard {
+	k := &Keyboard{Inline: true}
+	for _, e := range c.Elements {
+		k.Buttons = append(k.Buttons, e.Buttons)
+	}
+	return k
+}
+
+// replyParams - keyboard and template params of reply
+func replyParams(reply Reply) (H, error) {
+	params := H{}
+	if reply.Keyboard != nil && reply.Template != nil {
+		return nil, errors.New("vkapi: reply can't have keyboard and template")
+	}
+	if reply.Keyboard != nil {
+		keyboard, err := reply.Keyboard.JSON()
+		if err != nil {
+			return nil, fmt.Errorf("vkapi: encode keyboard: %w", err)
+		}
+		params["keyboard"] = keyboard
+	}
+	if reply.Template != nil {
+		template, err := reply.Template.JSON()
+		if err != nil {
+			return nil, err
+		}
+		params["template"] = template
+	}
+	return params, nil
+}
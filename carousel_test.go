@@ -0,0 +1,113 @@
+package govkbot
+
+import (
+	"strings"
+	"testing"
+)
+
+func testCarousel(n int) *Carousel {
+	var elements []CarouselElement
+	for i := 0; i < n; i++ {
+		elements = append(elements, CarouselElement{
+			Title:       "Item",
+			Description: "Description",
+			Action:      &CarouselAction{Type: CarouselOpenLink, Link: "https://vk.com"},
+			Buttons:     []Button{NewButton("/buy", nil)},
+		})
+	}
+	return NewCarousel(elements...)
+}
+
+func TestCarousel_Validate(t *testing.T) {
+	if err := testCarousel(2).Validate(); err != nil {
+		t.Error(err.Error())
+	}
+	if err := testCarousel(0).Validate(); err == nil {
+		t.Error("empty carousel must be invalid")
+	}
+	if err := testCarousel(11).Validate(); err == nil {
+		t.Error("too many elements must be invalid")
+	}
+	c := testCarousel(2)
+	c.Elements[1].Description = ""
+	if err := c.Validate(); err == nil {
+		t.Error("different elements must be invalid")
+	}
+	c = testCarousel(1)
+	c.Elements[0].Title = strings.Repeat("т", 81)
+	if err := c.Validate(); err == nil {
+		t.Error("long title must be invalid")
+	}
+	c = testCarousel(1)
+	c.Elements[0].Buttons = nil
+	if err := c.Validate(); err == nil {
+		t.Error("element without buttons must be invalid")
+	}
+	json, err := testCarousel(1).JSON()
+	if err != nil || !strings.Contains(json, `"type":"carousel"`) || !strings.Contains(json, `"link":"https://vk.com"`) {
+		t.Errorf("wrong json: %s %+v", json, err)
+	}
+}
+
+func TestVkAPI_SendCarousel(t *testing.T) {
+	SetAPI("", "test", "")
+	if _, err := API.SendAdvancedPeerMessage(1, Reply{Msg: "shop", Template: testCarousel(3)}); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := API.SendAdvancedPeerMessage(1, Reply{Msg: "shop", Template: testCarousel(0)}); err == nil {
+		t.Error("invalid carousel sent")
+	}
+	if _, err := API.SendAdvancedPeerMessage(1, Reply{Msg: "shop", Template: testCarousel(1), Keyboard: &Keyboard{}}); err == nil {
+		t.Error("keyboard with template sent")
+	}
+}
+
+func TestVKBot_CarouselFallback(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	m := &Message{PeerID: 1, UserID: 1, ClientInfo: &ClientInfo{Keyboard: true, InlineKeyboard: true}}
+	reply := bot.keyboardFallback(m, Reply{Msg: "shop", Template: testCarousel(2)})
+	if reply.Template != nil || reply.Keyboard == nil || !reply.Keyboard.Inline || len(reply.Keyboard.Buttons) != 2 {
+		t.Errorf("wrong fallback: %+v", reply)
+	}
+	if reply.Msg != "shop\n\nItem\nDescription\n\nItem\nDescription" {
+		t.Errorf("wrong fallback text: %q", reply.Msg)
+	}
+	m.ClientInfo.Carousel = true
+	if reply = bot.keyboardFallback(m, Reply{Msg: "shop", Template: testCarousel(2)}); reply.Template == nil {
+		t.Error("supported carousel replaced")
+	}
+}
+
+func TestVKBot_CarouselFallbackLimits(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	m := &Message{PeerID: 1, UserID: 1, ClientInfo: &ClientInfo{Keyboard: true, InlineKeyboard: true}}
+	carousel := testCarousel(CarouselMaxElements)
+	for i := range carousel.Elements {
+		carousel.Elements[i].Buttons = []Button{NewButton("/buy", nil), NewButton("/info", nil), NewButton("/share", nil)}
+	}
+	reply := bot.keyboardFallback(m, Reply{Msg: "shop", Template: carousel})
+	if reply.Template != nil || reply.Keyboard != nil {
+		t.Fatalf("keyboard over inline limits sent: %+v", reply)
+	}
+	if !strings.Contains(reply.Msg, "\n1. /buy\n") || !strings.HasSuffix(reply.Msg, "\n30. /share") {
+		t.Errorf("wrong text menu: %q", reply.Msg)
+	}
+	m.Body = "30"
+	if !bot.applyMenuChoice(m) || m.Body != "/share" {
+		t.Errorf("wrong menu choice: %q", m.Body)
+	}
+}
+
+func TestCarousel_JSONShared(t *testing.T) {
+	carousel := testCarousel(1)
+	carousel.Type = ""
+	s, err := carousel.JSON()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(s, `"type":"carousel"`) || carousel.Type != "" {
+		t.Errorf("wrong template %s, type %q", s, carousel.Type)
+	}
+}
@@ -0,0 +1,260 @@
+package govkbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	apiMessagesRemoveChatUser    = "messages.removeChatUser"
+	apiMessagesAddChatUser       = "messages.addChatUser"
+	apiMessagesGetInviteLink     = "messages.getInviteLink"
+	apiMessagesEditChat          = "messages.editChat"
+	apiMessagesSetChatPhoto      = "messages.setChatPhoto"
+	apiMessagesCreateChat        = "messages.createChat"
+	apiPhotosGetChatUploadServer = "photos.getChatUploadServer"
+)
+
+// VK API error codes
+const (
+	ErrCodeAccessDenied  = 15
+	ErrCodeChatNoAccess  = 917
+	ErrCodeNotChatAdmin  = 925
+	ErrCodeUserNotInChat = 935
+)
+
+// ErrNotChatAdmin - bot has no admin rights in chat. Check by errors.Is
+var ErrNotChatAdmin = errors.New("vkbot: bot is not chat admin")
+
+// ErrUserNotInChat - user is not member of chat. Check by errors.Is
+var ErrUserNotInChat = errors.New("vkbot: user is not in chat")
+
+// ChatError - VK error of chat administration method
+type ChatError struct {
+	*VKError
+	kind error
+}
+
+func (err *ChatError) Error() string {
+	return err.kind.Error() + ": " + err.VKError.Error()
+}
+
+// Is - matches ErrNotChatAdmin or ErrUserNotInChat
+func (err *ChatError) Is(target error) bool {
+	return target == err.kind
+}
+
+// Unwrap - returns VK error
+func (err *ChatError) Unwrap() error {
+	return err.VKError
+}
+
+// chatError - wraps VK errors about chat rights. Generic access denied (15) is not chat specific and not wrapped
+func chatError(err error) error {
+	var vkErr *VKError
+	if !errors.As(err, &vkErr) {
+		return err
+	}
+	switch vkErr.ErrorCode {
+	case ErrCodeChatNoAccess, ErrCodeNotChatAdmin:
+		return &ChatError{VKError: vkErr, kind: ErrNotChatAdmin}
+	case ErrCodeUserNotInChat:
+		return &ChatError{VKError: vkErr, kind: ErrUserNotInChat}
+	}
+	return err
+}
+
+// chatIDFromPeer - returns chat id for chat peer id, other ids unchanged
+func chatIDFromPeer(id int64) int64 {
+	if id > ChatPrefix {
+		return id - ChatPrefix
+	}
+	return id
+}
+
+// InviteLinkResponse - invite link response
+type InviteLinkResponse struct {
+	Response struct {
+		Link string `json:"link"`
+	}
+	Error *VKError
+}
+
+// UploadServerResponse - upload server response
+type UploadServerResponse struct {
+	Response struct {
+		UploadURL string `json:"upload_url"`
+	}
+	Error *VKError
+}
+
+// ChatPhotoResult - result of chat photo change
+type ChatPhotoResult struct {
+	MessageID int64 `json:"message_id"` // service message id
+	Chat      struct {
+		ID    int64  `json:"id"`
+		Title string `json:"title"`
+	} `json:"chat"`
+}
+
+// SetChatPhotoResponse - set chat photo response
+type SetChatPhotoResponse struct {
+	Response ChatPhotoResult
+	Error    *VKError
+}
+
+// RemoveChatUser - kick user (or group with negative id) from chat. Chat id or peer id accepted
+func (api *VkAPI) RemoveChatUser(chatID int64, memberID int64) error {
+	r := SimpleResponse{}
+	err := api.CallMethod(apiMessagesRemoveChatUser, H{
+		"chat_id":   strconv.FormatInt(chatIDFromPeer(chatID), 10),
+		"member_id": strconv.FormatInt(memberID, 10),
+	}, &r)
+	if err != nil {
+		return chatError(err)
+	}
+	api.invalidateChatID(chatID)
+	return nil
+}
+
+// AddChatUser - add user to chat (user tokens only)
+func (api *VkAPI) AddChatUser(chatID int64, userID int64) error {
+	r := SimpleResponse{}
+	err := api.CallMethod(apiMessagesAddChatUser, H{
+		"chat_id": strconv.FormatInt(chatIDFromPeer(chatID), 10),
+		"user_id": strconv.FormatInt(userID, 10),
+	}, &r)
+	if err != nil {
+		return chatError(err)
+	}
+	api.invalidateChatID(chatID)
+	return nil
+}
+
+// GetInviteLink - get chat invite link. Reset generates new link
+func (api *VkAPI) GetInviteLink(peerID int64, reset bool) (string, error) {
+	params := H{"peer_id": strconv.FormatInt(peerID, 10)}
+	if reset {
+		params["reset"] = "1"
+	}
+	if api.IsGroup() && api.GroupID != 0 {
+		params["group_id"] = strconv.FormatInt(api.GroupID, 10)
+	}
+	r := InviteLinkResponse{}
+	err := api.CallMethod(apiMessagesGetInviteLink, params, &r)
+	return r.Response.Link, chatError(err)
+}
+
+// EditChat - change chat title
+func (api *VkAPI) EditChat(chatID int64, title string) error {
+	r := SimpleResponse{}
+	err := api.CallMethod(apiMessagesEditChat, H{
+		"chat_id": strconv.FormatInt(chatIDFromPeer(chatID), 10),
+		"title":   title,
+	}, &r)
+	if err != nil {
+		return chatError(err)
+	}
+	api.invalidateChatID(chatID)
+	return nil
+}
+
+// CreateChat - create chat with users, returns chat id
+func (api *VkAPI) CreateChat(userIDs []int64, title string) (int64, error) {
+	params := H{
+		"user_ids": joinIDs(userIDs),
+		"title":    title,
+	}
+	if api.IsGroup() && api.GroupID != 0 {
+		params["group_id"] = strconv.FormatInt(api.GroupID, 10)
+	}
+	r := RawResponse{}
+	err := api.CallMethod(apiMessagesCreateChat, params, &r)
+	if err != nil {
+		return 0, chatError(err)
+	}
+	var chatID int64
+	if err = json.Unmarshal(r.Response, &chatID); err == nil {
+		return chatID, nil
+	}
+	chat := struct {
+		ChatID int64 `json:"chat_id"`
+	}{}
+	err = json.Unmarshal(r.Response, &chat)
+	return chat.ChatID, err
+}
+
+// SetChatPhotoFile - upload and set chat photo from file
+func (api *VkAPI) SetChatPhotoFile(chatID int64, filename string) (*ChatPhotoResult, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return api.SetChatPhoto(chatID, filepath.Base(filename), f)
+}
+
+// SetChatPhoto - upload and set chat photo
+func (api *VkAPI) SetChatPhoto(chatID int64, filename string, photo io.Reader) (*ChatPhotoResult, error) {
+	server := UploadServerResponse{}
+	err := api.CallMethod(apiPhotosGetChatUploadServer, H{
+		"chat_id": strconv.FormatInt(chatIDFromPeer(chatID), 10),
+	}, &server)
+	if err != nil {
+		return nil, chatError(err)
+	}
+	buf, err := api.upload(server.Response.UploadURL, "file", filename, photo)
+	if err != nil {
+		return nil, err
+	}
+	uploaded := struct {
+		Response string `json:"response"`
+	}{}
+	if err = json.Unmarshal(buf, &uploaded); err != nil || uploaded.Response == "" {
+		return nil, &ResponseError{errors.New("vkapi: wrong upload response"), string(buf)}
+	}
+	r := SetChatPhotoResponse{}
+	err = api.CallMethod(apiMessagesSetChatPhoto, H{"file": uploaded.Response}, &r)
+	if err != nil {
+		return nil, chatError(err)
+	}
+	api.invalidateChatID(chatID)
+	return &r.Response, nil
+}
+
+// invalidateChatID - drop cache of chat changed by bot. Chat id or peer id accepted
+func (api *VkAPI) invalidateChatID(chatID int64) {
+	api.InvalidateChat(ChatPrefix + chatIDFromPeer(chatID))
+}
+
+// upload - upload file to VK upload server as multipart form
+func (api *VkAPI) upload(uploadURL string, field string, filename string, r io.Reader) ([]byte, error) {
+	if api.URL == "test" {
+		return ioutil.ReadFile("./mocks/upload.json")
+	}
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	part, err := w.CreateFormFile(field, filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(part, r); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	resp, err := api.httpClient().Post(uploadURL, w.FormDataContentType(), body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,82 @@
+package govkbot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVkAPI_ChatAdmin(t *testing.T) {
+	SetAPI("", "test", "")
+	if err := API.RemoveChatUser(ChatPrefix+1, 2); err != nil {
+		t.Error(err.Error())
+	}
+	if err := API.AddChatUser(1, 2); err != nil {
+		t.Error(err.Error())
+	}
+	if err := API.EditChat(ChatPrefix+1, "test"); err != nil {
+		t.Error(err.Error())
+	}
+	link, err := API.GetInviteLink(ChatPrefix+1, true)
+	if err != nil || link != "https://vk.me/join/test" {
+		t.Errorf("wrong invite link: %s %+v", link, err)
+	}
+	chatID, err := API.CreateChat([]int64{1, 2}, "test")
+	if err != nil || chatID != 2 {
+		t.Errorf("wrong chat id: %d %+v", chatID, err)
+	}
+	photo, err := API.SetChatPhoto(ChatPrefix+1, "photo.jpg", strings.NewReader("jpg"))
+	if err != nil || photo.MessageID != 5 || photo.Chat.Title != "test" {
+		t.Errorf("wrong chat photo result: %+v %+v", photo, err)
+	}
+}
+
+func TestVkAPI_ChatAdminInvalidatesCache(t *testing.T) {
+	SetAPI("", "test", "")
+	API.SetCache(time.Minute, 100)
+	defer API.SetCache(0, 0)
+	changes := []func() error{
+		func() error { return API.RemoveChatUser(1, 2) },
+		func() error { return API.AddChatUser(ChatPrefix+1, 2) },
+		func() error { return API.EditChat(1, "test") },
+		func() error {
+			_, err := API.SetChatPhoto(ChatPrefix+1, "photo.jpg", strings.NewReader("jpg"))
+			return err
+		},
+	}
+	for i, change := range changes {
+		if _, err := API.GetChatUsers(ChatPrefix + 1); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := change(); err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, ok := API.cacheGet(chatCacheKey(ChatPrefix+1) + "users"); ok {
+			t.Errorf("change %d: chat cache not invalidated", i)
+		}
+	}
+}
+
+func TestChatError(t *testing.T) {
+	err := chatError(&VKError{ErrorCode: ErrCodeNotChatAdmin, ErrorMsg: "You are not admin of this chat"})
+	if !errors.Is(err, ErrNotChatAdmin) || errors.Is(err, ErrUserNotInChat) {
+		t.Errorf("wrong error: %+v", err)
+	}
+	var vkErr *VKError
+	if !errors.As(err, &vkErr) || vkErr.ErrorCode != ErrCodeNotChatAdmin {
+		t.Errorf("wrong vk error: %+v", err)
+	}
+	if err = chatError(&VKError{ErrorCode: ErrCodeUserNotInChat}); !errors.Is(err, ErrUserNotInChat) {
+		t.Errorf("wrong error: %+v", err)
+	}
+	if err = chatError(&VKError{ErrorCode: 113}); errors.Is(err, ErrNotChatAdmin) {
+		t.Errorf("wrong error: %+v", err)
+	}
+	if err = chatError(&VKError{ErrorCode: ErrCodeAccessDenied}); errors.Is(err, ErrNotChatAdmin) {
+		t.Errorf("access denied treated as not chat admin: %+v", err)
+	}
+	if chatError(nil) != nil {
+		t.Error(wrongValueReturned)
+	}
+}
@@ -0,0 +1,130 @@
+package govkbot
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// HandlerFunc - message handler with context
+type HandlerFunc func(*Context) error
+
+// Middleware - wraps message handler. Can stop routing by not calling next handler
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Context - inbound message context passed to handlers
+type Context struct {
+	Ctx     context.Context
+	Message *Message
+	Bot     *VKBot
+	API     *VkAPI
+	Command string
+	Args    []string
+	replies []Reply
+	values  map[string]interface{}
+	dialog  *dialogSession
+}
+
+func (bot *VKBot) newContext(ctx context.Context, m *Message) *Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &Context{
+		Ctx:     ctx,
+		Message: m,
+		Bot:     bot,
+		API:     bot.API,
+		values:  make(map[string]interface{}),
+	}
+}
+
+// Reply - add text reply to current peer. Replies are sent after handler returns
+func (c *Context) Reply(msg string) {
+	c.Respond(Reply{Msg: msg})
+}
+
+// ReplyKeyboard - add text reply with keyboard to current peer
+func (c *Context) ReplyKeyboard(msg string, keyboard *Keyboard) {
+	c.Respond(Reply{Msg: msg, Keyboard: keyboard})
+}
+
+// Respond - add reply to current peer. Empty replies are ignored
+func (c *Context) Respond(reply Reply) {
+	if reply.Msg != "" || reply.Keyboard != nil || reply.Template != nil {
+		c.replies = append(c.replies, reply)
+	}
+}
+
+// Replies - returns replies added by handlers
+func (c *Context) Replies() []Reply {
+	return c.replies
+}
+
+// SendTo - send message to peer immediately
+func (c *Context) SendTo(peerID int64, reply Reply) (SentMessage, error) {
+	return c.API.SendPeerReply(peerID, reply)
+}
+
+// Edit - edit sent message
+func (c *Context) Edit(sent SentMessage, reply Reply) error {
+	if sent.ConversationMessageID != 0 {
+		return c.API.EditConversationMessage(sent.PeerID, sent.ConversationMessageID, reply)
+	}
+	return c.API.EditMessage(sent.PeerID, sent.MessageID, reply)
+}
+
+// Delete - delete sent messages for all
+func (c *Context) Delete(messages ...SentMessage) error {
+	for _, sent := range messages {
+		var err error
+		if sent.ConversationMessageID != 0 {
+			err = c.API.DeleteConversationMessages(sent.PeerID, []int64{sent.ConversationMessageID}, true)
+		} else {
+			err = c.API.DeleteMessages(sent.PeerID, []int64{sent.MessageID}, true)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Typing - show "typing" status in current peer
+func (c *Context) Typing() error {
+	return c.API.SetActivity(c.Message.peer(), ActivityTyping)
+}
+
+// Arg - returns command argument by index or "" if not exists
+func (c *Context) Arg(i int) string {
+	if i < 0 || i >= len(c.Args) {
+		return ""
+	}
+	return c.Args[i]
+}
+
+// ArgInt64 - returns command argument by index as int64
+func (c *Context) ArgInt64(i int) (int64, error) {
+	return strconv.ParseInt(c.Arg(i), 10, 64)
+}
+
+// ArgsString - returns all command arguments as string
+func (c *Context) ArgsString() string {
+	return strings.Join(c.Args, " ")
+}
+
+// Set - set request value (for middlewares)
+func (c *Context) Set(key string, value interface{}) {
+	c.values[key] = value
+}
+
+// Get - get request value
+func (c *Context) Get(key string) (value interface{}, ok bool) {
+	value, ok = c.values[key]
+	return value, ok
+}
+
+// GetString - get request value as string
+func (c *Context) GetString(key string) string {
+	v, _ := c.values[key].(string)
+	return v
+}
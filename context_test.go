@@ -0,0 +1,86 @@
+package govkbot
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestContext_Args(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	bot.Handle("/sum", func(c *Context) error {
+		a, err := c.ArgInt64(0)
+		if err != nil {
+			return err
+		}
+		b, err := c.ArgInt64(1)
+		if err != nil {
+			return err
+		}
+		c.Reply(c.Command)
+		c.Reply(c.Arg(2))
+		if a+b != 5 {
+			return errors.New(wrongValueReturned)
+		}
+		return nil
+	})
+	replies, err := bot.RouteMessage(&Message{Body: "/SUM 2 3 ok"})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(replies) != 2 || replies[0].Msg != "/sum" || replies[1].Msg != "ok" {
+		t.Errorf("wrong replies: %+v", replies)
+	}
+	_, err = bot.RouteMessage(&Message{Body: "/sum a b"})
+	if err == nil {
+		t.Error("no error returned")
+	}
+}
+
+func TestContext_Middleware(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	bot.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if c.Message.UserID == 0 {
+				return nil
+			}
+			c.Set("user", "vk.com/id1")
+			return next(c)
+		}
+	})
+	bot.Handle("/me", func(c *Context) error {
+		c.Reply(c.GetString("user"))
+		return nil
+	})
+	replies, _ := bot.RouteMessage(&Message{Body: "/me"})
+	if len(replies) != 0 {
+		t.Error("middleware must stop routing")
+	}
+	replies, _ = bot.RouteMessage(&Message{Body: "/me", UserID: 1})
+	if len(replies) != 1 || replies[0].Msg != "vk.com/id1" {
+		t.Errorf("wrong replies: %+v", replies)
+	}
+}
+
+func TestContext_Helpers(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	c := bot.newContext(nil, &Message{PeerID: 1})
+	sent, err := c.SendTo(2, Reply{Msg: "ok"})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if sent.PeerID != 2 || sent.MessageID == 0 {
+		t.Errorf("wrong sent message: %+v", sent)
+	}
+	if err = c.Edit(sent, Reply{Msg: "ok", Keyboard: &Keyboard{}}); err != nil {
+		t.Error(err.Error())
+	}
+	if err = c.Delete(sent); err != nil {
+		t.Error(err.Error())
+	}
+	if err = c.Typing(); err != nil {
+		t.Error(err.Error())
+	}
+}
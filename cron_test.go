@@ -0,0 +1,45 @@
+package govkbot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, spec := range []string{"* * * * *", "0 9 * * 1-5", "*/15 0-6/2 1,15 * 7"} {
+		if _, err := parseCron(spec); err != nil {
+			t.Errorf("%s: %s", spec, err.Error())
+		}
+	}
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("%q: error expected", spec)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2024, 3, 1, 10, 30, 20, 0, time.UTC) // friday
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 1, 10, 31, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2024, 3, 1, 10, 40, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 0", time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC)},
+		{"0 12 15 * 0", time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := parseCron(c.spec)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if next := s.Next(from); !next.Equal(c.next) {
+			t.Errorf("%s: wrong next %v, expected %v", c.spec, next, c.next)
+		}
+	}
+}
@@ -1,13 +1,76 @@
 package govkbot
 
-import "log"
+import (
+	"errors"
+	"log/slog"
+	"net/url"
+	"os"
+	"sync"
+)
 
-func isDebugging() bool {
-	return API.DEBUG
+var (
+	debugLoggerOnce sync.Once
+	debugLogger     *slog.Logger
+)
+
+// logger - configured logger. If not set, DEBUG enables debug logs to stderr, otherwise slog.Default is used
+func (api *VkAPI) logger() *slog.Logger {
+	if api == nil {
+		return slog.Default()
+	}
+	if api.Logger != nil {
+		return api.Logger
+	}
+	if api.DEBUG {
+		debugLoggerOnce.Do(func() {
+			debugLogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		})
+		return debugLogger
+	}
+	return slog.Default()
+}
+
+// SetLogger - set bot logger, API logger is used if not set
+func (bot *VKBot) SetLogger(logger *slog.Logger) {
+	bot.logger = logger
+}
+
+// log - bot logger
+func (bot *VKBot) log() *slog.Logger {
+	if bot.logger != nil {
+		return bot.logger
+	}
+	return bot.API.logger()
 }
 
-func debugPrint(format string, values ...interface{}) {
-	if isDebugging() {
-		log.Printf("[VKBOT-DEBUG] "+format, values...)
+// paramsAttr - request params for logs, secrets skipped
+func paramsAttr(params map[string]string) slog.Attr {
+	attrs := make([]any, 0, len(params))
+	for k, v := range params {
+		if k == "access_token" || k == "key" {
+			continue
+		}
+		attrs = append(attrs, slog.String(k, v))
+	}
+	return slog.Group("params", attrs...)
+}
+
+// redactURLError - replace secret params in URL of request error, long poll URL contains key
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	u, perr := url.Parse(urlErr.URL)
+	if perr != nil {
+		return urlErr.Err
+	}
+	query := u.Query()
+	for _, k := range []string{"access_token", "key"} {
+		if query.Has(k) {
+			query.Set(k, "REDACTED")
+		}
 	}
+	u.RawQuery = query.Encode()
+	return &url.Error{Op: urlErr.Op, URL: u.String(), Err: urlErr.Err}
 }
@@ -0,0 +1,68 @@
+package govkbot
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error": {"error_code": 901, "error_msg": "Can't send messages"}}`))
+	}))
+	defer srv.Close()
+	out := &bytes.Buffer{}
+	api := &VkAPI{Token: "secret-token", URL: srv.URL + "/method/", Ver: "5.154",
+		Logger: slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+
+	r := struct{}{}
+	if err := api.CallMethod(apiMessagesSend, H{"peer_id": "5", "message": "hi"}, &r); err == nil {
+		t.Fatal("no VK error")
+	}
+	logs := out.String()
+	if strings.Contains(logs, "secret-token") || strings.Contains(logs, "access_token") {
+		t.Errorf("token in logs: %s", logs)
+	}
+	for _, field := range []string{"method=messages.send", "params.peer_id=5", "latency=", "error_code=901"} {
+		if !strings.Contains(logs, field) {
+			t.Errorf("no %s in logs: %s", field, logs)
+		}
+	}
+
+	bot := api.NewBot()
+	if bot.log() != api.Logger {
+		t.Error("bot not uses API logger")
+	}
+	logger := slog.New(slog.NewTextHandler(out, nil))
+	bot.SetLogger(logger)
+	if bot.log() != logger {
+		t.Error("bot logger not set")
+	}
+}
+
+func TestLogger_LongPollKey(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	addr := srv.URL
+	srv.Close()
+	out := &bytes.Buffer{}
+	api := &VkAPI{Logger: slog.New(slog.NewTextHandler(out, nil))}
+
+	group := &GroupLongPollServer{API: api, Server: addr + "/lp", Key: "secret-key", Ts: "1"}
+	_, gerr := group.Request()
+	user := &UserLongPollServer{API: api, Server: strings.TrimPrefix(addr, "http://") + "/lp", Key: "secret-key"}
+	_, uerr := user.Request()
+	if gerr == nil || uerr == nil {
+		t.Fatal("no request errors")
+	}
+	for _, text := range []string{out.String(), gerr.Error(), uerr.Error()} {
+		if strings.Contains(text, "secret-key") {
+			t.Errorf("long poll key not redacted: %s", text)
+		}
+	}
+	if strings.Count(out.String(), "long poll request failed") != 2 {
+		t.Errorf("request errors not logged: %s", out.String())
+	}
+}
@@ -0,0 +1,312 @@
+package govkbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCancelCommand - command to cancel active dialog
+const DefaultCancelCommand = "/cancel"
+
+// DialogKey - dialog state key, one dialog per user in peer
+type DialogKey struct {
+	PeerID int64
+	UserID int64
+}
+
+func (k DialogKey) String() string {
+	return fmt.Sprintf("%d:%d", k.PeerID, k.UserID)
+}
+
+// DialogState - current state of user dialog
+type DialogState struct {
+	Dialog    string            `json:"dialog"`
+	State     string            `json:"state"`
+	Data      map[string]string `json:"data"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+func (s *DialogState) clone() *DialogState {
+	c := *s
+	c.Data = make(map[string]string, len(s.Data))
+	for k, v := range s.Data {
+		c.Data[k] = v
+	}
+	return &c
+}
+
+// StateStorage - dialog states storage. GetState returns nil state if dialog not started
+type StateStorage interface {
+	GetState(key DialogKey) (*DialogState, error)
+	SetState(key DialogKey, state *DialogState) error
+	DeleteState(key DialogKey) error
+}
+
+// Dialog - multi-step conversation (finite state machine).
+// Start state handler called by dialog command, next handlers called on next user messages.
+// Handlers switch state by c.NextState and finish dialog by c.EndDialog
+type Dialog struct {
+	Name           string
+	Start          string
+	Timeout        time.Duration
+	TimeoutReply   string
+	CancelCommands []string
+	CancelReply    string
+	states         map[string]HandlerFunc
+}
+
+// NewDialog - create dialog with start state
+func NewDialog(name string, start string) *Dialog {
+	return &Dialog{
+		Name:           name,
+		Start:          start,
+		CancelCommands: []string{DefaultCancelCommand},
+		states:         make(map[string]HandlerFunc),
+	}
+}
+
+// State - add state handler
+func (d *Dialog) State(name string, handler HandlerFunc) *Dialog {
+	d.states[name] = handler
+	return d
+}
+
+func (d *Dialog) isCancel(message string) bool {
+	for _, cmd := range d.CancelCommands {
+		if strings.EqualFold(message, cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dialog) expired(state *DialogState) bool {
+	return d.Timeout > 0 && time.Since(state.UpdatedAt) > d.Timeout
+}
+
+type dialogSession struct {
+	key      DialogKey
+	state    *DialogState
+	finished bool
+}
+
+// HandleDialog - add dialog started by command
+func (bot *VKBot) HandleDialog(command string, d *Dialog, options ...RouteOption) {
+	bot.dialogs[d.Name] = d
+	bot.Handle(command, func(c *Context) error {
+		c.dialog = &dialogSession{
+			key:   dialogKey(c.Message),
+			state: &DialogState{Dialog: d.Name, State: d.Start, Data: make(map[string]string)},
+		}
+		return bot.runDialogState(c, d)
+	}, options...)
+}
+
+// SetStateStorage - set dialog states storage. Default is memory storage
+func (bot *VKBot) SetStateStorage(storage StateStorage) {
+	bot.stateStorage = storage
+}
+
+// CancelDialog - cancel active dialog of user in peer
+func (bot *VKBot) CancelDialog(peerID int64, userID int64) error {
+	return bot.stateStorage.DeleteState(DialogKey{PeerID: peerID, UserID: userID})
+}
+
+func dialogKey(m *Message) DialogKey {
+	return DialogKey{PeerID: m.PeerID, UserID: m.UserID}
+}
+
+// activeDialog - returns active dialog of message author or nil
+func (bot *VKBot) activeDialog(m *Message) (*Dialog, *DialogState, error) {
+	if len(bot.dialogs) == 0 {
+		return nil, nil, nil
+	}
+	key := dialogKey(m)
+	state, err := bot.stateStorage.GetState(key)
+	if err != nil || state == nil {
+		return nil, nil, err
+	}
+	d, ok := bot.dialogs[state.Dialog]
+	if !ok {
+		return nil, nil, bot.stateStorage.DeleteState(key)
+	}
+	return d, state, nil
+}
+
+// routeDialog - routes message to active dialog. Returns false if dialog expired and message must be routed
+func (bot *VKBot) routeDialog(ctx context.Context, m *Message, message string, d *Dialog, state *DialogState) (bool, []Reply, error) {
+	key := dialogKey(m)
+	var err error
+	if d.expired(state) {
+		err = bot.stateStorage.DeleteState(key)
+		if d.TimeoutReply != "" {
+			return true, []Reply{{Msg: d.TimeoutReply}}, err
+		}
+		return false, nil, err
+	}
+	if d.isCancel(message) {
+		err = bot.stateStorage.DeleteState(key)
+		if d.CancelReply != "" {
+			return true, []Reply{{Msg: d.CancelReply}}, err
+		}
+		return true, nil, err
+	}
+	c := bot.newContext(ctx, m)
+	c.Args = strings.Fields(message)
+	c.dialog = &dialogSession{key: key, state: state}
+	err = bot.wrapHandler(func(c *Context) error {
+		return bot.runDialogState(c, d)
+	})(c)
+	return true, c.Replies(), err
+}
+
+func (bot *VKBot) runDialogState(c *Context, d *Dialog) error {
+	handler, ok := d.states[c.dialog.state.State]
+	if !ok {
+		c.dialog.finished = true
+		bot.stateStorage.DeleteState(c.dialog.key)
+		return fmt.Errorf("vkbot: dialog %s has no state %s", d.Name, c.dialog.state.State)
+	}
+	err := handler(c)
+	if c.dialog.finished {
+		if serr := bot.stateStorage.DeleteState(c.dialog.key); serr != nil && err == nil {
+			err = serr
+		}
+		return err
+	}
+	c.dialog.state.UpdatedAt = time.Now()
+	if serr := bot.stateStorage.SetState(c.dialog.key, c.dialog.state); serr != nil && err == nil {
+		err = serr
+	}
+	return err
+}
+
+// NextState - switch active dialog to state. Handler of state called on next message
+func (c *Context) NextState(state string) {
+	if c.dialog != nil {
+		c.dialog.state.State = state
+		c.dialog.finished = false
+	}
+}
+
+// EndDialog - finish active dialog
+func (c *Context) EndDialog() {
+	if c.dialog != nil {
+		c.dialog.finished = true
+	}
+}
+
+// DialogState - returns current dialog state or "" if no active dialog
+func (c *Context) DialogState() string {
+	if c.dialog == nil {
+		return ""
+	}
+	return c.dialog.state.State
+}
+
+// DialogData - returns dialog data, saved between dialog steps
+func (c *Context) DialogData() map[string]string {
+	if c.dialog == nil {
+		return nil
+	}
+	return c.dialog.state.Data
+}
+
+// MemoryStateStorage - in memory dialog states storage
+type MemoryStateStorage struct {
+	mu     sync.Mutex
+	states map[DialogKey]*DialogState
+}
+
+// NewMemoryStateStorage - create memory dialog states storage
+func NewMemoryStateStorage() *MemoryStateStorage {
+	return &MemoryStateStorage{states: make(map[DialogKey]*DialogState)}
+}
+
+// GetState - get dialog state
+func (s *MemoryStateStorage) GetState(key DialogKey) (*DialogState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[key]
+	if !ok {
+		return nil, nil
+	}
+	return state.clone(), nil
+}
+
+// SetState - save dialog state
+func (s *MemoryStateStorage) SetState(key DialogKey, state *DialogState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[key] = state.clone()
+	return nil
+}
+
+// DeleteState - delete dialog state
+func (s *MemoryStateStorage) DeleteState(key DialogKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	return nil
+}
+
+// FileStateStorage - dialog states storage in JSON file. Survives bot restarts
+type FileStateStorage struct {
+	mu     sync.Mutex
+	path   string
+	states map[string]*DialogState
+}
+
+// NewFileStateStorage - create file dialog states storage and load saved states
+func NewFileStateStorage(path string) (*FileStateStorage, error) {
+	s := &FileStateStorage{path: path, states: make(map[string]*DialogState)}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &s.states); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// GetState - get dialog state
+func (s *FileStateStorage) GetState(key DialogKey) (*DialogState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[key.String()]
+	if !ok {
+		return nil, nil
+	}
+	return state.clone(), nil
+}
+
+// SetState - save dialog state
+func (s *FileStateStorage) SetState(key DialogKey, state *DialogState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[key.String()] = state.clone()
+	return writeJSONFile(s.path, s.states)
+}
+
+// DeleteState - delete dialog state
+func (s *FileStateStorage) DeleteState(key DialogKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.states[key.String()]; !ok {
+		return nil
+	}
+	delete(s.states, key.String())
+	return writeJSONFile(s.path, s.states)
+}
@@ -0,0 +1,121 @@
+package govkbot
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestDialog() *Dialog {
+	d := NewDialog("register", "start")
+	d.CancelReply = "cancelled"
+	d.State("start", func(c *Context) error {
+		c.Reply("name?")
+		c.NextState("name")
+		return nil
+	})
+	d.State("name", func(c *Context) error {
+		c.DialogData()["name"] = c.Message.Body
+		c.Reply("phone?")
+		c.NextState("phone")
+		return nil
+	})
+	d.State("phone", func(c *Context) error {
+		c.Reply(c.DialogData()["name"] + " " + c.Message.Body)
+		c.EndDialog()
+		return nil
+	})
+	return d
+}
+
+func routeReply(t *testing.T, bot *VKBot, body string) string {
+	replies, err := bot.RouteMessage(&Message{Body: body, PeerID: 1, UserID: 1})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(replies) == 0 {
+		return ""
+	}
+	return replies[0].Msg
+}
+
+func TestVKBot_HandleDialog(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	bot.HandleDialog("/register", newTestDialog())
+	bot.HandleMessage("/help", baseHandler)
+
+	steps := [][2]string{
+		{"/register", "name?"},
+		{"/help", "phone?"},
+		{"123", "/help 123"},
+		{"/help", "/help"},
+		{"/register", "name?"},
+		{"/cancel", "cancelled"},
+		{"/help", "/help"},
+	}
+	for _, step := range steps {
+		if reply := routeReply(t, bot, step[0]); reply != step[1] {
+			t.Errorf("%s: wrong reply %q, expected %q", step[0], reply, step[1])
+		}
+	}
+}
+
+func TestDialog_Timeout(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	d := newTestDialog()
+	d.Timeout = time.Millisecond
+	bot.HandleDialog("/register", d)
+	bot.HandleMessage("/help", baseHandler)
+	routeReply(t, bot, "/register")
+	time.Sleep(2 * time.Millisecond)
+	if reply := routeReply(t, bot, "/help"); reply != "/help" {
+		t.Errorf("dialog not expired: %q", reply)
+	}
+}
+
+func TestDialog_TimeoutThrottled(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	d := newTestDialog()
+	d.Timeout = time.Millisecond
+	bot.HandleDialog("/register", d)
+	bot.HandleMessage("/help", baseHandler)
+	bot.SetThrottle(ThrottleOptions{User: RateLimit{Count: 10, Interval: time.Minute}})
+	routeReply(t, bot, "/register")
+	time.Sleep(2 * time.Millisecond)
+	if reply := routeReply(t, bot, "/help"); reply != "/help" {
+		t.Errorf("expired dialog message dropped by throttler: %q", reply)
+	}
+}
+
+func TestFileStateStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "states.json")
+	s, err := NewFileStateStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := DialogKey{PeerID: 1, UserID: 2}
+	err = s.SetState(key, &DialogState{Dialog: "d", State: "s", Data: map[string]string{"a": "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewFileStateStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := s.GetState(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state == nil || state.State != "s" || state.Data["a"] != "b" {
+		t.Errorf("wrong state loaded: %+v", state)
+	}
+	if err = s.DeleteState(key); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ = s.GetState(key); state != nil {
+		t.Error("state not deleted")
+	}
+}
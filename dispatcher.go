@@ -0,0 +1,74 @@
+package govkbot
+
+import (
+	"sync"
+)
+
+// DefaultQueueSize - default messages queue size of one worker
+const DefaultQueueSize = 100
+
+// Dispatcher - processes messages in bounded worker pool.
+// Messages of one peer always processed by one worker, so their order is kept.
+// Dispatch blocks when worker queue is full
+type Dispatcher struct {
+	mu      sync.RWMutex
+	handler func(*Message)
+	queues  []chan *Message
+	wg      sync.WaitGroup
+	closed  bool
+}
+
+// NewDispatcher - create dispatcher and start workers
+func NewDispatcher(workers int, queueSize int, handler func(*Message)) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	d := &Dispatcher{handler: handler, queues: make([]chan *Message, workers)}
+	for i := range d.queues {
+		d.queues[i] = make(chan *Message, queueSize)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+func (d *Dispatcher) work(queue chan *Message) {
+	defer d.wg.Done()
+	for m := range queue {
+		d.handler(m)
+	}
+}
+
+// Dispatch - add message to queue of peer worker. Returns false if dispatcher stopped
+func (d *Dispatcher) Dispatch(m *Message) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return false
+	}
+	key := m.PeerID
+	if key == 0 {
+		key = m.UserID
+	}
+	if key < 0 {
+		key = -key
+	}
+	d.queues[key%int64(len(d.queues))] <- m
+	return true
+}
+
+// Stop - stop accepting messages and wait until queued messages processed
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, q := range d.queues {
+			close(q)
+		}
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
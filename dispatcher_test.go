@@ -0,0 +1,127 @@
+package govkbot
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatcher_PeerOrder(t *testing.T) {
+	var mu sync.Mutex
+	processed := make(map[int64][]int64)
+	d := NewDispatcher(4, 1, func(m *Message) {
+		if m.ID%3 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		mu.Lock()
+		processed[m.PeerID] = append(processed[m.PeerID], m.ID)
+		mu.Unlock()
+	})
+	for id := int64(1); id <= 30; id++ {
+		d.Dispatch(&Message{ID: id, PeerID: id % 5})
+	}
+	d.Stop()
+	if d.Dispatch(&Message{ID: 31}) {
+		t.Error("stopped dispatcher accepts messages")
+	}
+	count := 0
+	for peer, ids := range processed {
+		count += len(ids)
+		for i := 1; i < len(ids); i++ {
+			if ids[i] < ids[i-1] {
+				t.Errorf("peer %d: wrong order %v", peer, ids)
+			}
+		}
+	}
+	if count != 30 {
+		t.Errorf("processed %d messages, expected 30", count)
+	}
+}
+
+func TestDispatcher_Parallel(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan int64, 2)
+	d := NewDispatcher(2, 0, func(m *Message) {
+		if m.PeerID == 1 {
+			<-release
+		}
+		done <- m.PeerID
+	})
+	d.Dispatch(&Message{PeerID: 1})
+	d.Dispatch(&Message{PeerID: 2})
+	select {
+	case peer := <-done:
+		if peer != 2 {
+			t.Error("wrong peer processed")
+		}
+	case <-time.After(time.Second):
+		t.Error("slow peer blocks other peers")
+	}
+	close(release)
+	d.Stop()
+}
+
+func TestVKBot_SetWorkers(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	var mu sync.Mutex
+	count := 0
+	bot.Handle("hello", func(c *Context) error {
+		mu.Lock()
+		count++
+		mu.Unlock()
+		return nil
+	})
+	bot.SetWorkers(2, 10)
+	bot.MainRoute(NewUserLongPollServer(false, longPollVersion, 0))
+	bot.MainRoute(NewUserLongPollServer(false, longPollVersion, 0))
+	bot.Stop()
+	if count != 2 {
+		t.Errorf("processed %d messages, expected 2", count)
+	}
+}
+
+func TestVKBot_StopDrainsHandlers(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	var mu sync.Mutex
+	var errs []error
+	bot.Handle("hello", func(c *Context) error {
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		errs = append(errs, c.Ctx.Err())
+		mu.Unlock()
+		return nil
+	})
+	bot.SetWorkers(1, 10)
+	bot.MainRoute(NewUserLongPollServer(false, longPollVersion, 0))
+	bot.MainRoute(NewUserLongPollServer(false, longPollVersion, 0))
+	bot.Stop()
+	if len(errs) != 2 || errs[0] != nil || errs[1] != nil {
+		t.Errorf("queued messages not drained with live context: %v", errs)
+	}
+}
+
+func TestVKBot_StopCancelsHandlers(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	bot.SetStopTimeout(10 * time.Millisecond)
+	started := make(chan struct{})
+	var cancelled bool
+	bot.Handle("hello", func(c *Context) error {
+		close(started)
+		select {
+		case <-c.Ctx.Done():
+			cancelled = true
+		case <-time.After(time.Second):
+		}
+		return nil
+	})
+	bot.SetWorkers(1, 1)
+	bot.MainRoute(NewUserLongPollServer(false, longPollVersion, 0))
+	<-started
+	bot.Stop()
+	if !cancelled {
+		t.Error("handler context not cancelled after stop timeout")
+	}
+}
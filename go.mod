@@ -1,14 +1,3 @@
 module github.com/nikepan/govkbot/v2
 
-go 1.19
-
-require github.com/labstack/gommon v0.2.8
-
-require (
-	github.com/mattn/go-colorable v0.1.0 // indirect
-	github.com/mattn/go-isatty v0.0.4 // indirect
-	github.com/stretchr/testify v1.8.2 // indirect
-	github.com/valyala/bytebufferpool v1.0.0 // indirect
-	github.com/valyala/fasttemplate v0.0.0-20170224212429-dcecefd839c4 // indirect
-	golang.org/x/sys v0.6.0 // indirect
-)
+go 1.21
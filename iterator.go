@@ -0,0 +1,198 @@
+package govkbot
+
+import (
+	"strconv"
+)
+
+const (
+	apiMessagesGetConversations = "messages.getConversations"
+	apiMessagesGetHistory       = "messages.getHistory"
+)
+
+// pageSize - max items count per request of paged VK methods
+const pageSize = 200
+
+// Iterator - lazy iterator over paged VK API list. Pages are requested on demand:
+//
+//	it := API.History(peerID)
+//	for it.Next() {
+//		m := it.Item()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type Iterator[T any] struct {
+	fetch func() ([]T, bool, error)
+	items []T
+	i     int
+	more  bool
+	item  T
+	err   error
+}
+
+// NewOffsetIterator - iterator over offset paged method.
+// Page func returns items from offset and total items count
+func NewOffsetIterator[T any](count int, page func(offset int, count int) ([]T, int, error)) *Iterator[T] {
+	offset := 0
+	return &Iterator[T]{
+		more: true,
+		fetch: func() ([]T, bool, error) {
+			items, total, err := page(offset, count)
+			offset += len(items)
+			return items, len(items) > 0 && offset < total, err
+		},
+	}
+}
+
+// NewCursorIterator - iterator over cursor paged method.
+// Page func returns items from cursor ("" for first page) and next cursor, "" for last page
+func NewCursorIterator[T any](page func(cursor string) ([]T, string, error)) *Iterator[T] {
+	cursor := ""
+	return &Iterator[T]{
+		more: true,
+		fetch: func() ([]T, bool, error) {
+			items, next, err := page(cursor)
+			cursor = next
+			return items, next != "", err
+		},
+	}
+}
+
+// Next - moves to next item, requests next page if needed. Returns false on end or error
+func (it *Iterator[T]) Next() bool {
+	for it.i >= len(it.items) {
+		if !it.more || it.err != nil {
+			return false
+		}
+		it.items, it.more, it.err = it.fetch()
+		it.i = 0
+		if it.err != nil {
+			return false
+		}
+	}
+	it.item = it.items[it.i]
+	it.i++
+	return true
+}
+
+// Item - returns current item
+func (it *Iterator[T]) Item() T {
+	return it.item
+}
+
+// Err - returns request error
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All - reads all items
+func (it *Iterator[T]) All() ([]T, error) {
+	var items []T
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	return items, it.Err()
+}
+
+// ConversationItem - conversation with last message
+type ConversationItem struct {
+	Conversation ConversationInfo
+	LastMessage  *Message
+}
+
+// ConversationMember - chat member. Profile is set for users, Group for groups
+type ConversationMember struct {
+	MemberItem
+	Profile *UserProfile
+	Group   *GroupProfile
+}
+
+// Conversations - iterate conversations of bot. Filter is all, unread, important or unanswered, "" is all
+func (api *VkAPI) Conversations(filter string) *Iterator[ConversationItem] {
+	return NewOffsetIterator(pageSize, func(offset int, count int) ([]ConversationItem, int, error) {
+		params := H{
+			"offset": strconv.Itoa(offset),
+			"count":  strconv.Itoa(count),
+		}
+		if filter != "" {
+			params["filter"] = filter
+		}
+		r := struct {
+			Response struct {
+				Count int
+				Items []struct {
+					Conversation ConversationInfo
+					LastMessage  *APIMessage `json:"last_message"`
+				}
+			}
+		}{}
+		if err := api.CallMethod(apiMessagesGetConversations, params, &r); err != nil {
+			return nil, 0, err
+		}
+		items := make([]ConversationItem, 0, len(r.Response.Items))
+		for _, item := range r.Response.Items {
+			c := ConversationItem{Conversation: item.Conversation}
+			if item.LastMessage != nil {
+				c.LastMessage = item.LastMessage.Message()
+			}
+			items = append(items, c)
+		}
+		return items, r.Response.Count, nil
+	})
+}
+
+// History - iterate messages of peer from newest to oldest
+func (api *VkAPI) History(peerID int64) *Iterator[*Message] {
+	return NewOffsetIterator(pageSize, func(offset int, count int) ([]*Message, int, error) {
+		r := APIMessagesResponse{}
+		err := api.CallMethod(apiMessagesGetHistory, H{
+			"peer_id": strconv.FormatInt(peerID, 10),
+			"offset":  strconv.Itoa(offset),
+			"count":   strconv.Itoa(count),
+		}, &r)
+		if err != nil {
+			return nil, 0, err
+		}
+		messages := make([]*Message, 0, len(r.Response.Items))
+		for _, m := range r.Response.Items {
+			messages = append(messages, m.Message())
+		}
+		return messages, r.Response.Count, nil
+	})
+}
+
+// ConversationMembers - iterate members of chat
+func (api *VkAPI) ConversationMembers(peerID int64) *Iterator[ConversationMember] {
+	return NewOffsetIterator(pageSize, func(offset int, count int) ([]ConversationMember, int, error) {
+		r := struct {
+			Response struct {
+				Count int `json:"count"`
+				VKMembers
+			}
+		}{}
+		err := api.CallMethod(apiMessagesGetConversationMembers, H{
+			"peer_id": strconv.FormatInt(peerID, 10),
+			"offset":  strconv.Itoa(offset),
+			"count":   strconv.Itoa(count),
+			"fields":  "photo,city,country,sex,bdate,screen_name",
+		}, &r)
+		if err != nil {
+			return nil, 0, err
+		}
+		members := make([]ConversationMember, 0, len(r.Response.Items))
+		for _, item := range r.Response.Items {
+			member := ConversationMember{MemberItem: item}
+			for i := range r.Response.Profiles {
+				if r.Response.Profiles[i].ID == item.MemberID {
+					member.Profile = &r.Response.Profiles[i]
+				}
+			}
+			for i := range r.Response.Groups {
+				if -r.Response.Groups[i].ID == item.MemberID {
+					member.Group = &r.Response.Groups[i]
+				}
+			}
+			members = append(members, member)
+		}
+		return members, r.Response.Count, nil
+	})
+}
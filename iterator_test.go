@@ -0,0 +1,80 @@
+package govkbot
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestOffsetIterator(t *testing.T) {
+	data := []int{1, 2, 3, 4, 5}
+	requests := 0
+	it := NewOffsetIterator(2, func(offset int, count int) ([]int, int, error) {
+		requests++
+		end := offset + count
+		if end > len(data) {
+			end = len(data)
+		}
+		return data[offset:end], len(data), nil
+	})
+	items, err := it.All()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(items) != 5 || items[4] != 5 || requests != 3 {
+		t.Errorf("wrong items: %+v, requests %d", items, requests)
+	}
+}
+
+func TestCursorIterator(t *testing.T) {
+	it := NewCursorIterator(func(cursor string) ([]string, string, error) {
+		n, _ := strconv.Atoi(cursor)
+		if n == 2 {
+			return nil, "", errors.New("fail")
+		}
+		return []string{"page" + strconv.Itoa(n)}, strconv.Itoa(n + 1), nil
+	})
+	var items []string
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	if len(items) != 2 || items[1] != "page1" || it.Err() == nil {
+		t.Errorf("wrong items: %+v %+v", items, it.Err())
+	}
+	if it.Next() {
+		t.Error("next after error")
+	}
+}
+
+func TestVkAPI_Conversations(t *testing.T) {
+	SetAPI("", "test", "")
+	items, err := API.Conversations("unread").All()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(items) != 2 || items[1].Conversation.ChatSettings.Title != "test chat" || items[1].LastMessage.Body != "/help" {
+		t.Errorf("wrong conversations: %+v", items)
+	}
+}
+
+func TestVkAPI_History(t *testing.T) {
+	SetAPI("", "test", "")
+	messages, err := API.History(1).All()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(messages) != 2 || messages[0].Body != "second" || messages[1].ID != 10 {
+		t.Errorf("wrong messages: %+v", messages)
+	}
+}
+
+func TestVkAPI_ConversationMembers(t *testing.T) {
+	SetAPI("", "test", "")
+	members, err := API.ConversationMembers(ChatPrefix + 1).All()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(members) != 2 || members[0].Group == nil || members[1].Profile == nil || !members[1].IsAdmin {
+		t.Errorf("wrong members: %+v", members)
+	}
+}
@@ -0,0 +1,143 @@
+package govkbot
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	menuCacheSize = 10000
+	menuTTL       = time.Hour
+
+	// VK limits of inline keyboard
+	inlineKeyboardMaxRows    = 6
+	inlineKeyboardMaxButtons = 10
+)
+
+// menuOption - button of keyboard rendered as text menu
+type menuOption struct {
+	Label   string
+	Payload string
+}
+
+// Supports - client can show keyboard. Unknown client features treated as supported
+func (c *ClientInfo) Supports(k *Keyboard) bool {
+	if c == nil || k == nil {
+		return true
+	}
+	if k.Inline && !c.InlineKeyboard || !k.Inline && !c.Keyboard {
+		return false
+	}
+	if len(c.ButtonActions) == 0 {
+		return true
+	}
+	for _, row := range k.Buttons {
+		for _, b := range row {
+			if !c.SupportsAction(b.Action.Type) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// fitsInline - inline keyboard is in VK limits, keyboard without inline flag always fits
+func (k *Keyboard) fitsInline() bool {
+	if !k.Inline {
+		return true
+	}
+	if len(k.Buttons) > inlineKeyboardMaxRows {
+		return false
+	}
+	buttons := 0
+	for _, row := range k.Buttons {
+		buttons += len(row)
+	}
+	return buttons <= inlineKeyboardMaxButtons
+}
+
+func menuKey(m *Message) string {
+	return strconv.FormatInt(m.PeerID, 10) + ":" + strconv.FormatInt(m.UserID, 10)
+}
+
+// keyboardFallback - replace carousel and keyboard not supported by client or exceeding inline limits
+// with numbered text menu
+func (bot *VKBot) keyboardFallback(m *Message, reply Reply) Reply {
+	if reply.Template != nil && m.ClientInfo != nil && !m.ClientInfo.Carousel {
+		reply = carouselFallback(reply)
+	}
+	if reply.Keyboard == nil {
+		// text menu is outdated by newer reply
+		bot.menus.Delete(menuKey(m))
+		return reply
+	}
+	if m.ClientInfo.Supports(reply.Keyboard) && reply.Keyboard.fitsInline() {
+		return reply
+	}
+	var options []menuOption
+	var menu strings.Builder
+	for _, row := range reply.Keyboard.Buttons {
+		for _, b := range row {
+			if b.Action.Label == "" {
+				continue
+			}
+			options = append(options, menuOption{Label: b.Action.Label, Payload: b.Action.Payload})
+			menu.WriteString("\n" + strconv.Itoa(len(options)) + ". " + b.Action.Label)
+		}
+	}
+	reply.Keyboard = nil
+	if len(options) == 0 {
+		return reply
+	}
+	bot.menus.Set(menuKey(m), options, menuTTL)
+	if reply.Msg != "" {
+		reply.Msg += "\n\n"
+	}
+	reply.Msg += menu.String()[1:]
+	return reply
+}
+
+// carouselFallback - replace carousel with text and inline keyboard of element buttons
+func carouselFallback(reply Reply) Reply {
+	var text strings.Builder
+	text.WriteString(reply.Msg)
+	for _, e := range reply.Template.Elements {
+		line := e.Title
+		if e.Description != "" {
+			line = strings.TrimSpace(line + "\n" + e.Description)
+		}
+		if line != "" {
+			if text.Len() > 0 {
+				text.WriteString("\n\n")
+			}
+			text.WriteString(line)
+		}
+	}
+	reply.Msg = text.String()
+	reply.Keyboard = reply.Template.Keyboard()
+	reply.Template = nil
+	return reply
+}
+
+// applyMenuChoice - replace number of text menu option with button label and payload.
+// Menu is used once. Returns true if message is menu choice
+func (bot *VKBot) applyMenuChoice(m *Message) bool {
+	if m.Payload != "" || m.Action != "" {
+		return false
+	}
+	key := menuKey(m)
+	cached, ok := bot.menus.Get(key)
+	if !ok {
+		return false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(m.Body))
+	options := cached.([]menuOption)
+	if err != nil || n < 1 || n > len(options) {
+		return false
+	}
+	bot.menus.Delete(key)
+	m.Body = options[n-1].Label
+	m.Payload = options[n-1].Payload
+	return true
+}
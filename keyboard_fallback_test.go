@@ -0,0 +1,94 @@
+package govkbot
+
+import (
+	"testing"
+)
+
+func testKeyboard(inline bool, action string) *Keyboard {
+	b1 := NewButton("/help", map[string]string{"command": "help"})
+	b2 := NewButton("/start", nil)
+	b2.Action.Type = action
+	return &Keyboard{Inline: inline, Buttons: [][]Button{{b1}, {b2}}}
+}
+
+func TestClientInfo_Supports(t *testing.T) {
+	var unknown *ClientInfo
+	if !unknown.Supports(testKeyboard(true, "text")) {
+		t.Error("unknown client must be supported")
+	}
+	c := &ClientInfo{Keyboard: true, ButtonActions: []string{"text"}}
+	if !c.Supports(testKeyboard(false, "text")) {
+		t.Error("keyboard must be supported")
+	}
+	if c.Supports(testKeyboard(true, "text")) {
+		t.Error("inline keyboard must not be supported")
+	}
+	if c.Supports(testKeyboard(false, "callback")) {
+		t.Error("callback button must not be supported")
+	}
+}
+
+func TestVKBot_KeyboardFallback(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	m := &Message{PeerID: 1, UserID: 1, ClientInfo: &ClientInfo{Keyboard: true, ButtonActions: []string{"text"}}}
+	reply := bot.keyboardFallback(m, Reply{Msg: "Choose:", Keyboard: testKeyboard(true, "callback")})
+	if reply.Keyboard != nil || reply.Msg != "Choose:\n\n1. /help\n2. /start" {
+		t.Errorf("wrong fallback: %q", reply.Msg)
+	}
+	if _, err := bot.Reply(m, Reply{Msg: "Choose:", Keyboard: testKeyboard(true, "text")}); err != nil {
+		t.Error(err.Error())
+	}
+
+	var payload string
+	bot.Handle("/help", func(c *Context) error {
+		payload = c.Message.Payload
+		c.Reply("help")
+		return nil
+	})
+	replies, err := bot.RouteMessage(&Message{PeerID: 1, UserID: 1, Body: " 1 "})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(replies) != 1 || payload != `{"command":"help"}` {
+		t.Errorf("wrong menu choice: %+v %s", replies, payload)
+	}
+	if replies, _ = bot.RouteMessage(&Message{PeerID: 1, UserID: 2, Body: "1"}); len(replies) != 0 {
+		t.Errorf("menu of other user used: %+v", replies)
+	}
+	if replies, _ = bot.RouteMessage(&Message{PeerID: 1, UserID: 1, Body: "3"}); len(replies) != 0 {
+		t.Errorf("wrong option used: %+v", replies)
+	}
+}
+
+func TestVKBot_MenuChoiceOutdated(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	bot.HandleDialog("/order", newTestDialog())
+	bot.Handle("/help", func(c *Context) error {
+		c.Reply("help")
+		return nil
+	})
+	m := &Message{PeerID: 1, UserID: 1, ClientInfo: &ClientInfo{Keyboard: true, ButtonActions: []string{"text"}}}
+	menu := Reply{Msg: "Choose:", Keyboard: testKeyboard(true, "text")}
+
+	bot.keyboardFallback(m, menu)
+	if replies, _ := bot.RouteMessage(&Message{PeerID: 1, UserID: 1, Body: "1"}); len(replies) != 1 {
+		t.Errorf("menu choice not applied: %+v", replies)
+	}
+	if replies, _ := bot.RouteMessage(&Message{PeerID: 1, UserID: 1, Body: "1"}); len(replies) != 0 {
+		t.Errorf("menu used twice: %+v", replies)
+	}
+
+	bot.keyboardFallback(m, menu)
+	bot.keyboardFallback(m, Reply{Msg: "How old are you?"})
+	if replies, _ := bot.RouteMessage(&Message{PeerID: 1, UserID: 1, Body: "1"}); len(replies) != 0 {
+		t.Errorf("menu used after reply without keyboard: %+v", replies)
+	}
+
+	bot.keyboardFallback(m, menu)
+	routeReply(t, bot, "/order")
+	if reply := routeReply(t, bot, "2"); reply != "phone?" {
+		t.Errorf("dialog answer replaced by menu choice: %q", reply)
+	}
+}
@@ -3,10 +3,7 @@ package govkbot
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io/ioutil"
-	"log"
-	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -90,16 +87,27 @@ func NewGroupLongPollServer(requestInterval int) (resp *GroupLongPollServer) {
 	return &server
 }
 
+// api - returns server API, global API if not set
+func (server *GroupLongPollServer) api() *VkAPI {
+	if server.API != nil {
+		return server.API
+	}
+	return API
+}
+
 // Init - init longpoll server
 func (server *GroupLongPollServer) Init() (err error) {
+	api := server.api()
 	r := GroupLongPollServerResponse{}
-	err = API.CallMethod("groups.getLongPollServer", H{
-		"group_id": strconv.FormatInt(API.GroupID, 10),
+	err = api.CallMethod("groups.getLongPollServer", H{
+		"group_id": strconv.FormatInt(api.GroupID, 10),
 	}, &r)
-	server.Wait = DefaultWait
+	if server.Wait == 0 {
+		server.Wait = DefaultWait
+	}
 	server.Mode = DefaultMode
 	server.Version = DefaultVersion
-	server.RequestInterval = API.RequestInterval
+	server.RequestInterval = api.RequestInterval
 	server.Server = r.Response.Server
 	server.Ts = r.Response.Ts
 	server.Key = r.Response.Key
@@ -118,7 +126,7 @@ func (server *GroupLongPollServer) Request() ([]byte, error) {
 	if server.Server == "" {
 		err = server.Init()
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 	}
 
@@ -132,15 +140,18 @@ func (server *GroupLongPollServer) Request() ([]byte, error) {
 		content, err := ioutil.ReadFile("./mocks/longpoll.json")
 		return content, err
 	}
-	resp, err := http.Get(query)
+	resp, err := server.api().httpClient().Get(query)
 	if err != nil {
-		debugPrint("%+v\n", err.Error())
+		err = redactURLError(err)
+		server.api().logger().Warn("long poll request failed", "error", err)
 		time.Sleep(time.Duration(time.Millisecond * time.Duration(server.RequestInterval)))
 		return nil, err
 	}
 	buf, err := ioutil.ReadAll(resp.Body)
-	time.Sleep(time.Duration(time.Millisecond * time.Duration(server.RequestInterval)))
-	//debugPrint("longpoll vk resp: %+v\n", string(buf))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
 
 	failResp := GroupFailResponse{}
 	err = json.Unmarshal(buf, &failResp)
@@ -151,19 +162,19 @@ func (server *GroupLongPollServer) Request() ([]byte, error) {
 	case 1:
 		server.Ts, err = failResp.TS()
 		if err != nil {
-			log.Printf("error ts: %+v\n", err)
+			server.api().logger().Warn("long poll ts", "error", err)
 		}
 		return server.Request()
 	case 2:
 		err = server.Init()
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		return server.Request()
 	case 3:
 		err = server.Init()
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		return server.Request()
 	case 4:
@@ -171,7 +182,7 @@ func (server *GroupLongPollServer) Request() ([]byte, error) {
 	default:
 		server.Ts, err = failResp.TS()
 		if err != nil {
-			log.Printf("error ts: %+v\n", err)
+			server.api().logger().Warn("long poll ts", "error", err)
 		}
 		return buf, nil
 	}
@@ -184,6 +195,9 @@ func (server *GroupLongPollServer) GetLongPollMessages() ([]*Message, error) {
 		return nil, err
 	}
 	messages, err := server.ParseLongPollMessages(string(resp))
+	if err != nil {
+		return nil, err
+	}
 	return messages.Messages, nil
 }
 
@@ -194,7 +208,7 @@ func (server *GroupLongPollServer) ParseMessage(obj map[string]interface{}) (Mes
 		msg.Body = obj["text"].(string)
 	} else {
 		msg.Body = ""
-		fmt.Printf("error parse message: %+v\n", obj)
+		server.api().logger().Warn("parse message: no text", "message_id", msg.ID)
 		return msg, errors.New("error parse message")
 	}
 	userID := getJSONInt64(obj["from_id"])
@@ -208,12 +222,30 @@ func (server *GroupLongPollServer) ParseMessage(obj map[string]interface{}) (Mes
 		msg.ChatID = msg.PeerID
 	}
 	msg.Date = getJSONInt(obj["date"])
+	msg.ConversationMessageID = getJSONInt64(obj["conversation_message_id"])
+	if payload, ok := obj["payload"].(string); ok {
+		msg.Payload = payload
+	}
+	if action, ok := obj["action"].(map[string]interface{}); ok {
+		if actionType, ok := action["type"].(string); ok {
+			msg.Action = actionType
+		}
+		msg.ActionMID = getJSONInt64(action["member_id"])
+	}
+	if reply, ok := obj["reply_message"].(map[string]interface{}); ok {
+		replyMsg, err := server.ParseMessage(reply)
+		if err != nil {
+			server.api().logger().Warn("parse reply message", "message_id", msg.ID, "error", err)
+		} else {
+			msg.ReplyMessage = &replyMsg
+		}
+	}
 	fwd, ok := obj["fwd_messages"]
 	if ok {
 		for _, m := range fwd.([]interface{}) {
 			fwdMsg, err := server.ParseMessage(m.(map[string]interface{}))
 			if err != nil {
-				fmt.Printf("error parse fwd message: %+v\n", err)
+				server.api().logger().Warn("parse forwarded message", "message_id", msg.ID, "error", err)
 			} else {
 				msg.FwdMessages = append(msg.FwdMessages, fwdMsg)
 			}
@@ -222,9 +254,24 @@ func (server *GroupLongPollServer) ParseMessage(obj map[string]interface{}) (Mes
 	return msg, nil
 }
 
+// parseClientInfo - parse client_info object of message_new event
+func parseClientInfo(obj interface{}) *ClientInfo {
+	if obj == nil {
+		return nil
+	}
+	buf, err := json.Marshal(obj)
+	if err != nil {
+		return nil
+	}
+	info := ClientInfo{}
+	if err = json.Unmarshal(buf, &info); err != nil {
+		return nil
+	}
+	return &info
+}
+
 // ParseLongPollMessages - parse longpoll messages
 func (server *GroupLongPollServer) ParseLongPollMessages(j string) (*GroupLongPollResponse, error) {
-	//fmt.Printf("\n>>>>>>>>>>>>>updates0: %+v\n\n", j)
 	d := json.NewDecoder(strings.NewReader(j))
 	d.UseNumber()
 	var lp interface{}
@@ -235,31 +282,30 @@ func (server *GroupLongPollServer) ParseLongPollMessages(j string) (*GroupLongPo
 	result := GroupLongPollResponse{Messages: []*Message{}}
 	result.Ts = lpMap["ts"].(string)
 	updates := lpMap["updates"].([]interface{})
-	//fmt.Printf("\n>>>>>>>>>>>>>updates: %+v\n\n", updates)
 	for _, event := range updates {
 		//el := event.(interface{})
 		eventType := event.(map[string]interface{})["type"].(string)
 		if eventType == "message_new" {
 			obj := event.(map[string]interface{})["object"].(map[string]interface{})
+			// API 5.103+ sends message with client info, older versions send message fields in object
+			var clientInfo *ClientInfo
+			if message, ok := obj["message"].(map[string]interface{}); ok {
+				clientInfo = parseClientInfo(obj["client_info"])
+				obj = message
+			}
 			out := getJSONInt(obj["out"])
 			if out == 0 {
-				debugPrint("new message: %+v\n", obj)
 				msg, err := server.ParseMessage(obj)
+				msg.ClientInfo = clientInfo
 				result.Messages = append(result.Messages, &msg)
 				if err != nil {
-					fmt.Printf("error parse message: %+v \n>>>%+v \n>>>>> %+v\n", err, obj, msg)
+					server.api().logger().Warn("parse message", "peer_id", msg.PeerID, "message_id", msg.ID, "error", err)
 				}
 			}
 		}
 	}
-	if len(result.Messages) == 0 {
-		debugPrint(j)
-	}
-	if len(result.Messages) > 0 {
-		debugPrint("new messages: ts: %+v = %+v\n", result.Ts, len(result.Messages))
-	}
+	server.api().logger().Debug("long poll updates", "ts", result.Ts, "updates", len(updates), "messages", len(result.Messages))
 	// result.Messages = server.FilterReadMesages(result.Messages)
-	// fmt.Printf("\n>>>>>>>>>>>>>messages2: %+v\n\n", result)
 	return &result, nil
 }
 
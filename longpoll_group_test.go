@@ -0,0 +1,42 @@
+package govkbot
+
+import (
+	"testing"
+)
+
+func TestGroupLongPollServer_ParseLongPollMessages(t *testing.T) {
+	server := NewGroupLongPollServer(0)
+	data := `{"ts": "10", "updates": [
+		{"type": "message_new", "group_id": 1, "object": {
+			"message": {"id": 5, "date": 1700000000, "from_id": 1, "peer_id": 1, "out": 0, "text": "/help",
+				"conversation_message_id": 3, "payload": "{\"command\":\"help\"}"},
+			"client_info": {"button_actions": ["text", "callback"], "keyboard": true, "inline_keyboard": true,
+				"carousel": false, "lang_id": 0}
+		}},
+		{"type": "message_new", "group_id": 1, "object": {
+			"id": 6, "date": 1700000001, "from_id": 2, "peer_id": 2000000001, "out": 0, "text": "old"
+		}},
+		{"type": "message_new", "group_id": 1, "object": {
+			"message": {"id": 7, "from_id": -1, "peer_id": 1, "out": 1, "text": "outgoing"},
+			"client_info": {}
+		}}
+	]}`
+	result, err := server.ParseLongPollMessages(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(result.Messages) != 2 {
+		t.Fatalf("wrong messages: %+v", result.Messages)
+	}
+	m := result.Messages[0]
+	if m.ID != 5 || m.Body != "/help" || m.ConversationMessageID != 3 || m.Payload != `{"command":"help"}` {
+		t.Errorf("wrong message: %+v", m)
+	}
+	if m.ClientInfo == nil || !m.ClientInfo.InlineKeyboard || !m.ClientInfo.SupportsAction("callback") || m.ClientInfo.Carousel {
+		t.Errorf("wrong client info: %+v", m.ClientInfo)
+	}
+	old := result.Messages[1]
+	if old.Body != "old" || old.ChatID != 2000000001 || old.ClientInfo != nil {
+		t.Errorf("wrong old message: %+v", old)
+	}
+}
@@ -4,13 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
-	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/labstack/gommon/log"
 )
 
 const DefaultWait = 25
@@ -110,6 +107,14 @@ func NewUserLongPollServer(needPts bool, lpVersion int, requestInterval int) (re
 	return &server
 }
 
+// api - returns server API, global API if not set
+func (server *UserLongPollServer) api() *VkAPI {
+	if server.API != nil {
+		return server.API
+	}
+	return API
+}
+
 // Init - init longpoll server
 func (server *UserLongPollServer) Init() (err error) {
 	r := UserLongPollServerResponse{}
@@ -117,14 +122,17 @@ func (server *UserLongPollServer) Init() (err error) {
 	if server.NeedPts {
 		pts = 1
 	}
-	err = API.CallMethod("messages.getLongPollServer", H{
+	api := server.api()
+	err = api.CallMethod("messages.getLongPollServer", H{
 		"need_pts": strconv.Itoa(pts),
 		"message":  strconv.Itoa(server.LpVersion),
 	}, &r)
-	server.Wait = DefaultWait
+	if server.Wait == 0 {
+		server.Wait = DefaultWait
+	}
 	server.Mode = DefaultMode
 	server.Version = DefaultVersion
-	server.RequestInterval = API.RequestInterval
+	server.RequestInterval = api.RequestInterval
 	server.Server = r.Response.Server
 	server.Ts = r.Response.Ts
 	server.Key = r.Response.Key
@@ -138,7 +146,7 @@ func (server *UserLongPollServer) Request() ([]byte, error) {
 	if server.Server == "" {
 		err = server.Init()
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 	}
 
@@ -154,20 +162,23 @@ func (server *UserLongPollServer) Request() ([]byte, error) {
 		content, err := ioutil.ReadFile("./mocks/longpoll.json")
 		return content, err
 	}
-	resp, err := http.Get(query)
+	resp, err := server.api().httpClient().Get(query)
 	if err != nil {
-		debugPrint("%+v\n", err.Error())
+		err = redactURLError(err)
+		server.api().logger().Warn("long poll request failed", "error", err)
 		time.Sleep(time.Duration(time.Millisecond * time.Duration(server.RequestInterval)))
 		return nil, err
 	}
 	buf, err := ioutil.ReadAll(resp.Body)
-	time.Sleep(time.Duration(time.Millisecond * time.Duration(server.RequestInterval)))
-	//debugPrint("longpoll vk resp: %+v\n", string(buf))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
 
 	failResp := FailResponse{}
 	err = json.Unmarshal(buf, &failResp)
 	if err != nil {
-		log.Printf("longpoll vk resp: %+v\n", string(buf))
+		server.api().logger().Warn("long poll response is not json", "bytes", len(buf), "error", err)
 		return nil, err
 	}
 	switch failResp.Failed {
@@ -177,13 +188,13 @@ func (server *UserLongPollServer) Request() ([]byte, error) {
 	case 2:
 		err = server.Init()
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		return server.Request()
 	case 3:
 		err = server.Init()
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		return server.Request()
 	case 4:
@@ -213,6 +224,9 @@ func (server *UserLongPollServer) GetLongPollMessages() ([]*Message, error) {
 		return nil, err
 	}
 	messages, err := server.ParseLongPollMessages(string(resp))
+	if err != nil {
+		return nil, err
+	}
 	return messages.Messages, nil
 }
 
@@ -261,14 +275,11 @@ func (server *UserLongPollServer) ParseLongPollMessages(j string) (*LongPollResp
 					msg.ChatID = msg.PeerID - ChatPrefix
 				}
 				msg.Date = getJSONInt(el[4])
-				debugPrint(msg.Body)
 				result.Messages = append(result.Messages, &msg)
 			}
 		}
 	}
-	if len(result.Messages) == 0 {
-		debugPrint(j)
-	}
+	server.api().logger().Debug("long poll updates", "ts", result.Ts, "messages", len(result.Messages))
 	result.Messages = server.FilterReadMesages(result.Messages)
 	return &result, nil
 }
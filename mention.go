@@ -0,0 +1,255 @@
+package govkbot
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Mention types
+const (
+	MentionUser  = "user"
+	MentionGroup = "group"
+)
+
+var mentionPrefixes = []struct {
+	prefix string
+	kind   string
+}{
+	{"id", MentionUser},
+	{"club", MentionGroup},
+	{"public", MentionGroup},
+}
+
+// GetMentions - returns all users and groups mentions in message body
+func (m Message) GetMentions() []Mention {
+	return ParseMentions(m.Body)
+}
+
+// ParseMentions - find all mentions in text: [id1|Name], [club1|Name], [public1|Name],
+// @screen_name, *screen_name and @id1 (Name) forms. Offsets are in bytes and in runes
+func ParseMentions(text string) []Mention {
+	mentions := make([]Mention, 0)
+	runes := 0
+	last := 0
+	for i := 0; i < len(text); {
+		var m Mention
+		ok := false
+		switch text[i] {
+		case '[':
+			m, ok = parseBracketMention(text, i)
+		case '@', '*':
+			prev, _ := utf8.DecodeLastRuneInString(text[:i])
+			if i == 0 || !isNameRune(prev) {
+				m, ok = parseScreenMention(text, i)
+			}
+		}
+		if !ok {
+			i++
+			continue
+		}
+		runes += utf8.RuneCountInString(text[last:i])
+		m.RuneOffset = runes
+		m.RuneLength = utf8.RuneCountInString(text[i : i+m.Length])
+		runes += m.RuneLength
+		last = i + m.Length
+		i = last
+		mentions = append(mentions, m)
+	}
+	return mentions
+}
+
+// mentionID - parse id1, club1 and public1 forms
+func mentionID(s string) (int64, string, bool) {
+	for _, p := range mentionPrefixes {
+		if !strings.HasPrefix(s, p.prefix) || len(s) == len(p.prefix) {
+			continue
+		}
+		digits := s[len(p.prefix):]
+		for i := 0; i < len(digits); i++ {
+			if digits[i] < '0' || digits[i] > '9' {
+				return 0, "", false
+			}
+		}
+		id, err := strconv.ParseInt(digits, 10, 64)
+		return id, p.kind, err == nil
+	}
+	return 0, "", false
+}
+
+func parseBracketMention(text string, start int) (Mention, bool) {
+	m := Mention{Offset: start}
+	rest := text[start+1:]
+	end := strings.IndexAny(rest, "[]\n")
+	if end < 0 || rest[end] != ']' {
+		return m, false
+	}
+	sep := strings.IndexByte(rest[:end], '|')
+	if sep < 0 {
+		return m, false
+	}
+	id, kind, ok := mentionID(rest[:sep])
+	if !ok {
+		return m, false
+	}
+	m.ID = id
+	m.Type = kind
+	m.Name = rest[sep+1 : end]
+	m.Length = end + 2
+	return m, true
+}
+
+func parseScreenMention(text string, start int) (Mention, bool) {
+	m := Mention{Offset: start}
+	j := start + 1
+	for j < len(text) && isScreenNameByte(text[j]) {
+		j++
+	}
+	for j > start+1 && text[j-1] == '.' {
+		j--
+	}
+	name := text[start+1 : j]
+	if name == "" {
+		return m, false
+	}
+	m.ScreenName = name
+	m.Name = name
+	if id, kind, ok := mentionID(name); ok {
+		m.ID = id
+		m.Type = kind
+		m.ScreenName = ""
+	}
+	if strings.HasPrefix(text[j:], " (") {
+		display := text[j+2:]
+		if end := strings.IndexAny(display, "()\n"); end > 0 && display[end] == ')' {
+			m.Name = display[:end]
+			j += 2 + end + 1
+		}
+	}
+	m.Length = j - start
+	return m, true
+}
+
+func isScreenNameByte(c byte) bool {
+	return c == '_' || c == '.' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// FormatMention - returns mention text for message. Mention without ID formatted as @screen_name (Name)
+func FormatMention(m Mention) string {
+	name := strings.NewReplacer("[", "(", "]", ")", "|", "/").Replace(m.Name)
+	if m.ID == 0 {
+		if name == "" || name == m.ScreenName {
+			return "@" + m.ScreenName
+		}
+		return "@" + m.ScreenName + " (" + name + ")"
+	}
+	prefix := "id"
+	if m.Type == MentionGroup {
+		prefix = "club"
+	}
+	if name == "" {
+		name = "@" + prefix + strconv.FormatInt(m.ID, 10)
+	}
+	return "[" + prefix + strconv.FormatInt(m.ID, 10) + "|" + name + "]"
+}
+
+// UserMention - returns [id1|Name] mention
+func UserMention(userID int64, name string) string {
+	return FormatMention(Mention{ID: userID, Name: name, Type: MentionUser})
+}
+
+// GroupMention - returns [club1|Name] mention
+func GroupMention(groupID int64, name string) string {
+	return FormatMention(Mention{ID: groupID, Name: name, Type: MentionGroup})
+}
+
+// SetMentionOnly - in group chats route only messages addressed to bot:
+// with bot mention, reply to bot message or starting with bot screen name.
+// Bot mention is removed from message body before routing
+func (bot *VKBot) SetMentionOnly(mentionOnly bool) {
+	bot.mentionOnly = mentionOnly
+}
+
+// addressed - checks message addressed to bot and returns body without bot mention
+func (bot *VKBot) addressed(m *Message) (bool, string) {
+	body := m.Body
+	isGroup := bot.API.IsGroup()
+	ok := false
+	mentions := ParseMentions(body)
+	for i := len(mentions) - 1; i >= 0; i-- {
+		mention := mentions[i]
+		if bot.isMe(mention) {
+			ok = true
+			body = body[:mention.Offset] + body[mention.Offset+mention.Length:]
+		}
+	}
+	if ok {
+		return true, trimAddress(body)
+	}
+	if m.ReplyMessage != nil {
+		from := m.ReplyMessage.UserID
+		if (isGroup && from == -bot.API.GroupID) || (!isGroup && from == bot.API.UID) {
+			return true, body
+		}
+	}
+	if name := bot.screenName(); name != "" {
+		text := strings.TrimSpace(body)
+		if HasPrefix(text, name) {
+			next, _ := utf8.DecodeRuneInString(text[len(name):])
+			if next == utf8.RuneError || !isNameRune(next) {
+				return true, trimAddress(text[len(name):])
+			}
+		}
+	}
+	return false, body
+}
+
+// isMe - checks mention is bot mention
+func (bot *VKBot) isMe(m Mention) bool {
+	if m.ID == 0 {
+		name := bot.screenName()
+		return name != "" && strings.EqualFold(m.ScreenName, name)
+	}
+	if bot.API.IsGroup() {
+		return m.Type == MentionGroup && m.ID == bot.API.GroupID
+	}
+	return m.Type == MentionUser && m.ID == bot.API.UID
+}
+
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.'
+}
+
+// trimAddress - trim spaces and punctuation after address like "[club1|bot], /help"
+func trimAddress(body string) string {
+	return strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(body), ",:"))
+}
+
+// screenName - returns cached bot screen name. Lock is not held during request,
+// messages routed while name is requested are checked without it
+func (bot *VKBot) screenName() string {
+	bot.nameMu.Lock()
+	if bot.name != "" || time.Since(bot.nameChecked) < time.Minute {
+		defer bot.nameMu.Unlock()
+		return bot.name
+	}
+	bot.nameChecked = time.Now()
+	bot.nameMu.Unlock()
+
+	var name string
+	if bot.API.IsGroup() {
+		if g, err := bot.API.CurrentGroup(); err == nil && g != nil {
+			name = g.ScreenName
+		}
+	} else if u, err := bot.API.Me(); err == nil && u != nil {
+		name = u.ScreenName
+	}
+	bot.nameMu.Lock()
+	defer bot.nameMu.Unlock()
+	if name != "" {
+		bot.name = name
+	}
+	return bot.name
+}
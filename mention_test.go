@@ -0,0 +1,140 @@
+package govkbot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMessage_GetMentionsAll(t *testing.T) {
+	m := Message{Body: "[club12|@bot], спроси [id1|Павел] и [public3|Page] [id|bad] [id5 no"}
+	mentions := m.GetMentions()
+	expected := []Mention{
+		{ID: 12, Name: "@bot", Type: MentionGroup, Offset: 0, Length: 13, RuneOffset: 0, RuneLength: 13},
+		{ID: 1, Name: "Павел", Type: MentionUser, Offset: 28, Length: 16, RuneOffset: 22, RuneLength: 11},
+		{ID: 3, Name: "Page", Type: MentionGroup, Offset: 48, Length: 14, RuneOffset: 36, RuneLength: 14},
+	}
+	if len(mentions) != len(expected) {
+		t.Fatalf("wrong mentions: %+v", mentions)
+	}
+	for i, e := range expected {
+		if mentions[i] != e {
+			t.Errorf("wrong mention %+v, expected %+v", mentions[i], e)
+		}
+	}
+}
+
+func TestParseMentions_ScreenNames(t *testing.T) {
+	text := "hi @durov (Павел), *id5 and @club7. mail@example.com * @"
+	mentions := ParseMentions(text)
+	expected := []Mention{
+		{Name: "Павел", ScreenName: "durov", Offset: 3, Length: 19, RuneOffset: 3, RuneLength: 14},
+		{ID: 5, Name: "id5", Type: MentionUser, Offset: 24, Length: 4, RuneOffset: 19, RuneLength: 4},
+		{ID: 7, Name: "club7", Type: MentionGroup, Offset: 33, Length: 6, RuneOffset: 28, RuneLength: 6},
+	}
+	if len(mentions) != len(expected) {
+		t.Fatalf("wrong mentions: %+v", mentions)
+	}
+	for i, e := range expected {
+		if mentions[i] != e {
+			t.Errorf("wrong mention %+v, expected %+v", mentions[i], e)
+		}
+		if text[e.Offset:e.Offset+e.Length] != string([]rune(text)[e.RuneOffset:e.RuneOffset+e.RuneLength]) {
+			t.Errorf("byte and rune spans differ: %+v", e)
+		}
+	}
+}
+
+func TestFormatMention(t *testing.T) {
+	cases := map[string]string{
+		UserMention(1, "Pavel"):                                "[id1|Pavel]",
+		GroupMention(2, "Bot [test]"):                          "[club2|Bot (test)]",
+		UserMention(3, ""):                                     "[id3|@id3]",
+		FormatMention(Mention{ScreenName: "durov"}):            "@durov",
+		FormatMention(Mention{ScreenName: "durov", Name: "P"}): "@durov (P)",
+	}
+	for result, expected := range cases {
+		if result != expected {
+			t.Errorf("wrong mention %q, expected %q", result, expected)
+		}
+	}
+	for _, m := range ParseMentions(UserMention(1, "Pavel") + " " + FormatMention(Mention{ScreenName: "durov", Name: "P"})) {
+		if m.Name == "" || (m.ID == 0 && m.ScreenName == "") {
+			t.Errorf("formatted mention not parsed: %+v", m)
+		}
+	}
+}
+
+func TestVKBot_SetMentionOnly(t *testing.T) {
+	SetAPI("", "test", "")
+	API.GroupID = 1
+	defer func() { API.GroupID = 0 }()
+	bot := API.NewBot()
+	bot.HandleMessage("/help", baseHandler)
+	bot.SetMentionOnly(true)
+
+	chat := int64(ChatPrefix + 1)
+	cases := []struct {
+		m      Message
+		result string
+	}{
+		{Message{Body: "/help", PeerID: chat}, ""},
+		{Message{Body: "[club1|@testbot], /help me", PeerID: chat}, "/help me"},
+		{Message{Body: "[club2|@other] /help", PeerID: chat}, ""},
+		{Message{Body: "@testbot /help", PeerID: chat}, "/help"},
+		{Message{Body: "testbot: /help", PeerID: chat}, "/help"},
+		{Message{Body: "testbot2 /help", PeerID: chat}, ""},
+		{Message{Body: "/help", PeerID: chat, ReplyMessage: &Message{UserID: -1}}, "/help"},
+		{Message{Body: "/help", PeerID: 1, UserID: 1}, "/help"},
+	}
+	for _, c := range cases {
+		m := c.m
+		replies, err := bot.RouteMessage(&m)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		result := ""
+		if len(replies) > 0 {
+			result = replies[0].Msg
+		}
+		if result != c.result {
+			t.Errorf("%q: wrong reply %q, expected %q", c.m.Body, result, c.result)
+		}
+	}
+}
+
+func TestVKBot_ScreenNameNoLock(t *testing.T) {
+	group, err := os.ReadFile("./mocks/" + apiGroupsGet + ".json")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	requested := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requested)
+		<-release
+		w.Write(group)
+	}))
+	defer srv.Close()
+	bot := (&VkAPI{Token: "token", URL: srv.URL + "/method/", GroupID: 1}).NewBot()
+
+	done := make(chan string)
+	go func() { done <- bot.screenName() }()
+	<-requested
+	locked := make(chan struct{})
+	go func() {
+		bot.getDispatcher()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Error("bot locked during screen name request")
+	}
+	close(release)
+	if name := <-done; name != "testbot" {
+		t.Errorf("wrong screen name %q", name)
+	}
+}
@@ -0,0 +1,102 @@
+package govkbot
+
+import (
+	"strconv"
+	"time"
+)
+
+// DefaultAccessDeniedMessage - default reply for not allowed commands
+const DefaultAccessDeniedMessage = "Access denied"
+
+// chat members cache of permission checks, works without API cache
+const (
+	chatMembersCacheSize = 1000
+	chatMembersCacheTTL  = time.Minute
+)
+
+// RouteOption - message route option
+type RouteOption func(*msgRoute)
+
+type routeCheck func(c *Context) (bool, error)
+
+func (r msgRoute) allowed(c *Context) (bool, error) {
+	for _, check := range r.checks {
+		ok, err := check(c)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// RequireBotAdmin - allow command only for bot admin (VkAPI.AdminID)
+func RequireBotAdmin() RouteOption {
+	return func(r *msgRoute) {
+		r.checks = append(r.checks, func(c *Context) (bool, error) {
+			return c.API.AdminID != 0 && c.Message.UserID == c.API.AdminID, nil
+		})
+	}
+}
+
+// RequireChatAdmin - allow command only for chat admins and owner. Works only in group chats
+func RequireChatAdmin() RouteOption {
+	return func(r *msgRoute) {
+		r.checks = append(r.checks, func(c *Context) (bool, error) {
+			if !c.Message.IsChat() {
+				return false, nil
+			}
+			return c.Bot.IsChatAdmin(c.Message.PeerID, c.Message.UserID)
+		})
+	}
+}
+
+// RequirePrivateChat - allow command only in private messages
+func RequirePrivateChat() RouteOption {
+	return func(r *msgRoute) {
+		r.checks = append(r.checks, func(c *Context) (bool, error) {
+			return !c.Message.IsChat(), nil
+		})
+	}
+}
+
+// RequireGroupChat - allow command only in group chats
+func RequireGroupChat() RouteOption {
+	return func(r *msgRoute) {
+		r.checks = append(r.checks, func(c *Context) (bool, error) {
+			return c.Message.IsChat(), nil
+		})
+	}
+}
+
+// SetAccessDeniedMessage - set reply for commands not allowed to user. Empty string disables reply
+func (bot *VKBot) SetAccessDeniedMessage(msg string) {
+	bot.accessDenied = msg
+}
+
+// IsChatAdmin - checks user is admin or owner of chat. Chat members are cached for a minute,
+// cache is dropped on chat events
+func (bot *VKBot) IsChatAdmin(peerID int64, userID int64) (bool, error) {
+	users, err := bot.chatUsers(peerID)
+	if err != nil {
+		return false, err
+	}
+	u := FindUser(users, userID)
+	return u != nil && (u.IsAdmin || u.IsOwner), nil
+}
+
+func (bot *VKBot) chatUsers(peerID int64) ([]*User, error) {
+	key := strconv.FormatInt(peerID, 10)
+	if users, ok := bot.chatMembers.Get(key); ok {
+		return users.([]*User), nil
+	}
+	chatID := peerID
+	if !bot.API.IsGroup() {
+		chatID = peerID - ChatPrefix
+	}
+	users, err := bot.API.GetChatUsers(chatID)
+	if err != nil {
+		return nil, err
+	}
+	bot.chatMembers.Set(key, users, chatMembersCacheTTL)
+	return users, nil
+}
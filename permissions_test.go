@@ -0,0 +1,80 @@
+package govkbot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestRouteOptions(t *testing.T) {
+	SetAPI("", "test", "")
+	API.AdminID = 3
+	bot := API.NewBot()
+	bot.HandleMessage("/ban", baseHandler, RequireChatAdmin())
+	bot.HandleMessage("/restart", baseHandler, RequireBotAdmin())
+	bot.HandleMessage("/start", baseHandler, RequirePrivateChat())
+	bot.HandleMessage("/top", baseHandler, RequireGroupChat())
+
+	chat := int64(ChatPrefix + 1)
+	cases := []struct {
+		body   string
+		user   int64
+		peer   int64
+		result string
+	}{
+		{"/ban", 1, chat, "/ban"},
+		{"/ban", 2, chat, DefaultAccessDeniedMessage},
+		{"/ban", 1, 1, DefaultAccessDeniedMessage},
+		{"/restart", 3, 3, "/restart"},
+		{"/restart", 1, 1, DefaultAccessDeniedMessage},
+		{"/start", 1, 1, "/start"},
+		{"/start", 1, chat, DefaultAccessDeniedMessage},
+		{"/top", 1, chat, "/top"},
+		{"/top", 1, 1, DefaultAccessDeniedMessage},
+	}
+	for _, c := range cases {
+		replies, err := bot.RouteMessage(&Message{Body: c.body, UserID: c.user, PeerID: c.peer})
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if len(replies) != 1 || replies[0].Msg != c.result {
+			t.Errorf("%s by %d in %d: wrong replies %+v", c.body, c.user, c.peer, replies)
+		}
+	}
+
+	bot.SetAccessDeniedMessage("")
+	replies, _ := bot.RouteMessage(&Message{Body: "/ban", UserID: 2, PeerID: chat})
+	if len(replies) != 0 {
+		t.Errorf("access denied reply not disabled: %+v", replies)
+	}
+	API.AdminID = 0
+}
+
+func TestVKBot_IsChatAdminCache(t *testing.T) {
+	members, err := os.ReadFile("./mocks/" + apiMessagesGetConversationMembers + ".json")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(members)
+	}))
+	defer srv.Close()
+	bot := (&VkAPI{Token: "token", URL: srv.URL + "/method/", GroupID: 1}).NewBot()
+
+	for i := 0; i < 3; i++ {
+		if ok, err := bot.IsChatAdmin(ChatPrefix+1, 1); err != nil || !ok {
+			t.Fatalf("wrong admin check: %v %v", ok, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("chat members requested %d times without API cache", requests)
+	}
+	bot.RouteMessage(&Message{PeerID: ChatPrefix + 1, UserID: 1, Action: "chat_kick_user", ActionMID: 2})
+	bot.IsChatAdmin(ChatPrefix+1, 1)
+	if requests != 2 {
+		t.Errorf("chat members not invalidated by chat event: %d requests", requests)
+	}
+}
@@ -0,0 +1,129 @@
+package govkbot
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = time.Minute
+	defaultJitter     = 0.2
+)
+
+// PollOptions - long poll loop options
+type PollOptions struct {
+	Wait       int           // long poll wait in seconds
+	MinBackoff time.Duration // first delay after poll error
+	MaxBackoff time.Duration // max delay after repeated poll errors
+	Jitter     float64       // random part of backoff delay, 0..1, negative disables jitter
+	OnPoll     func(PollStats)
+}
+
+// PollStats - stats of one long poll request
+type PollStats struct {
+	Latency  time.Duration
+	Messages int
+	Err      error
+}
+
+// DefaultPollOptions - returns default long poll loop options
+func DefaultPollOptions() PollOptions {
+	return PollOptions{
+		Wait:       DefaultWait,
+		MinBackoff: defaultMinBackoff,
+		MaxBackoff: defaultMaxBackoff,
+		Jitter:     defaultJitter,
+	}
+}
+
+// SetPollOptions - set long poll loop options. Zero fields are taken from DefaultPollOptions
+func (bot *VKBot) SetPollOptions(options PollOptions) {
+	defaults := DefaultPollOptions()
+	if options.Wait <= 0 {
+		options.Wait = defaults.Wait
+	}
+	if options.MinBackoff <= 0 {
+		options.MinBackoff = defaults.MinBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaults.MaxBackoff
+	}
+	if options.MaxBackoff < options.MinBackoff {
+		options.MaxBackoff = options.MinBackoff
+	}
+	if options.Jitter == 0 {
+		options.Jitter = defaults.Jitter
+	}
+	bot.pollOptions = options
+}
+
+// Poll - request messages from long poll server and process them. Returns messages count
+func (bot *VKBot) Poll(poller LongPollServer) (int, error) {
+	messages, err := poller.GetLongPollMessages()
+	if err != nil {
+		return 0, err
+	}
+	bot.log().Debug("inbox", "messages", len(messages))
+	for _, m := range messages {
+		if !bot.accept(m) {
+			continue
+		}
+		if dispatcher := bot.getDispatcher(); dispatcher != nil && dispatcher.Dispatch(m) {
+			continue
+		}
+		bot.ProcessMessage(bot.handlerCtx, m)
+	}
+	return len(messages), nil
+}
+
+// listen - poll server until bot stopped. Next request starts right after previous batch
+func (bot *VKBot) listen(poller LongPollServer) error {
+	var backoff time.Duration
+	bot.startScheduler()
+	for !bot.stopped() {
+		start := time.Now()
+		n, err := bot.Poll(poller)
+		stats := PollStats{Latency: time.Since(start), Messages: n, Err: err}
+		bot.log().Debug("poll", "latency", stats.Latency, "messages", stats.Messages, "error", stats.Err)
+		if bot.pollOptions.OnPoll != nil {
+			bot.pollOptions.OnPoll(stats)
+		}
+		if err == nil {
+			backoff = 0
+			continue
+		}
+		backoff = bot.pollOptions.nextBackoff(backoff)
+		// poll errors are transient, bot retries them with backoff
+		if bot.errorHandler != nil {
+			bot.errorHandler(nil, err)
+		} else {
+			bot.log().Warn("poll failed", "error", err, "backoff", backoff)
+		}
+		select {
+		case <-bot.ctx.Done():
+		case <-time.After(bot.pollOptions.withJitter(backoff)):
+		}
+	}
+	return nil
+}
+
+func (o PollOptions) nextBackoff(backoff time.Duration) time.Duration {
+	if backoff == 0 {
+		backoff = o.MinBackoff
+	} else {
+		backoff *= 2
+	}
+	if o.MaxBackoff > 0 && backoff > o.MaxBackoff {
+		backoff = o.MaxBackoff
+	}
+	return backoff
+}
+
+func (o PollOptions) withJitter(d time.Duration) time.Duration {
+	if o.Jitter <= 0 || d <= 0 {
+		return d
+	}
+	delta := float64(d) * o.Jitter
+	return d + time.Duration(delta*(2*rand.Float64()-1))
+}
@@ -0,0 +1,151 @@
+package govkbot
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testPoller struct {
+	UserLongPollServer
+	batches [][]*Message
+	errs    []error
+}
+
+func (p *testPoller) GetLongPollMessages() ([]*Message, error) {
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return nil, err
+	}
+	if len(p.batches) == 0 {
+		return nil, nil
+	}
+	batch := p.batches[0]
+	p.batches = p.batches[1:]
+	return batch, nil
+}
+
+func TestVKBot_Listen(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	bot.HandleError(errorHandler)
+	received := 0
+	bot.Handle("/", func(c *Context) error {
+		received++
+		return nil
+	})
+	poller := &testPoller{
+		errs:    []error{errors.New("network"), errors.New("network")},
+		batches: [][]*Message{{{Body: "/a"}, {Body: "/b"}}, {{Body: "/c"}}},
+	}
+	var stats []PollStats
+	options := DefaultPollOptions()
+	options.MinBackoff = time.Millisecond
+	options.OnPoll = func(s PollStats) {
+		stats = append(stats, s)
+		if len(stats) == 4 {
+			bot.Stop()
+		}
+	}
+	bot.SetPollOptions(options)
+
+	done := make(chan error)
+	go func() { done <- bot.listen(poller) }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("listen not stopped")
+	}
+	if received != 3 {
+		t.Errorf("received %d messages, expected 3", received)
+	}
+	if stats[0].Err == nil || stats[2].Messages != 2 || stats[3].Messages != 1 {
+		t.Errorf("wrong poll stats: %+v", stats)
+	}
+}
+
+func TestVKBot_ListenWithoutErrorHandler(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	out := &bytes.Buffer{}
+	bot.SetLogger(slog.New(slog.NewTextHandler(out, nil)))
+	poller := &testPoller{errs: []error{errors.New("network"), errors.New("network")}}
+	options := DefaultPollOptions()
+	options.MinBackoff = time.Millisecond
+	polls := 0
+	options.OnPoll = func(s PollStats) {
+		polls++
+		if polls == 3 {
+			bot.Stop()
+		}
+	}
+	bot.SetPollOptions(options)
+
+	done := make(chan error)
+	go func() { done <- bot.listen(poller) }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("listen not stopped")
+	}
+	if strings.Count(out.String(), "poll failed") != 2 {
+		t.Errorf("poll errors not logged: %s", out.String())
+	}
+}
+
+func TestVKBot_SetPollOptionsPartial(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Write([]byte(`{"error": {"error_code": 5, "error_msg": "User authorization failed"}}`))
+	}))
+	defer srv.Close()
+	api := &VkAPI{Token: "token", URL: srv.URL + "/method/", GroupID: 1}
+	bot := api.NewBot()
+	bot.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	bot.SetPollOptions(PollOptions{OnPoll: func(PollStats) {}})
+	if bot.pollOptions.MinBackoff != defaultMinBackoff || bot.pollOptions.Wait != DefaultWait {
+		t.Errorf("zero options not filled: %+v", bot.pollOptions)
+	}
+
+	done := make(chan error)
+	go func() { done <- bot.ListenGroup(api) }()
+	time.Sleep(200 * time.Millisecond)
+	bot.Stop()
+	<-done
+	mu.Lock()
+	defer mu.Unlock()
+	if requests > 2 {
+		t.Errorf("failing server requested %d times without backoff", requests)
+	}
+}
+
+func TestPollOptions_Backoff(t *testing.T) {
+	o := PollOptions{MinBackoff: time.Second, MaxBackoff: 3 * time.Second}
+	b := o.nextBackoff(0)
+	if b != time.Second {
+		t.Error(wrongValueReturned)
+	}
+	b = o.nextBackoff(o.nextBackoff(b))
+	if b != 3*time.Second {
+		t.Error(wrongValueReturned)
+	}
+	o.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		d := o.withJitter(time.Second)
+		if d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Errorf("wrong jitter: %v", d)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package govkbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultPollingInterval - pause between polling requests when no new messages
+const DefaultPollingInterval = 3 * time.Second
+
+// PollingServer - polling fallback for environments where long poll is blocked.
+// Requests unread conversations and their history, tracks last processed message of each peer.
+// Last processed ids are kept in memory by default: after restart without MarkRead and Store
+// messages of still unread conversations are routed again and bot replies to them twice
+type PollingServer struct {
+	API      *VkAPI
+	Interval time.Duration
+	MarkRead bool         // mark processed messages as read, so they not returned as unread again. Off by default
+	Store    PollingStore // last processed ids storage, survives restarts if persistent
+
+	mu      sync.Mutex
+	lastIDs map[int64]int64
+	done    <-chan struct{} // interrupts interval wait, set by bot
+}
+
+// PollingStore - last processed message ids storage of polling server
+type PollingStore interface {
+	LoadLastIDs() (map[int64]int64, error)
+	SaveLastIDs(lastIDs map[int64]int64) error
+}
+
+// NewPollingServer - create polling server
+func NewPollingServer(api *VkAPI, interval time.Duration) *PollingServer {
+	if interval <= 0 {
+		interval = DefaultPollingInterval
+	}
+	return &PollingServer{
+		API:      api,
+		Interval: interval,
+	}
+}
+
+// Init - nothing to init for polling
+func (server *PollingServer) Init() error {
+	return nil
+}
+
+// Request - request unread conversations
+func (server *PollingServer) Request() ([]byte, error) {
+	return server.API.Call(apiMessagesGetConversations, H{"filter": "unread", "count": "200"})
+}
+
+// GetLongPollMessages - get new messages, waits interval if there are no messages
+func (server *PollingServer) GetLongPollMessages() ([]*Message, error) {
+	messages, err := server.GetMessages()
+	if err == nil && len(messages) == 0 {
+		select {
+		case <-time.After(server.Interval):
+		case <-server.done:
+		}
+	}
+	return messages, err
+}
+
+// GetMessages - get new incoming messages of unread conversations, oldest first
+func (server *PollingServer) GetMessages() ([]*Message, error) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if err := server.loadLastIDs(); err != nil {
+		return nil, err
+	}
+	conversations := server.API.Conversations("unread")
+	var result []*Message
+	changed := false
+	for conversations.Next() {
+		c := conversations.Item().Conversation
+		peerID := c.Peer.ID
+		lastID := server.lastIDs[peerID]
+		if int64(c.InRead) > lastID {
+			lastID = int64(c.InRead)
+		}
+		var messages []*Message
+		history := server.API.History(peerID)
+		for history.Next() {
+			m := history.Item()
+			if m.ID <= lastID {
+				break
+			}
+			if m.Out == 0 {
+				messages = append(messages, m)
+			}
+			if m.ID > server.lastIDs[peerID] {
+				server.lastIDs[peerID] = m.ID
+				changed = true
+			}
+		}
+		if err := history.Err(); err != nil {
+			return result, err
+		}
+		if server.MarkRead && len(messages) > 0 {
+			if err := server.API.MarkAsRead(peerID, 0); err != nil {
+				server.API.logger().Warn("mark as read", "peer_id", peerID, "error", err)
+			}
+		}
+		for i := len(messages) - 1; i >= 0; i-- {
+			result = append(result, messages[i])
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Date < result[j].Date })
+	if changed && server.Store != nil {
+		// messages are already taken, so save error is not returned to not lose them
+		if err := server.Store.SaveLastIDs(server.lastIDs); err != nil {
+			server.API.logger().Warn("save polling last ids", "error", err)
+		}
+	}
+	return result, conversations.Err()
+}
+
+// loadLastIDs - load last processed ids from store on first request
+func (server *PollingServer) loadLastIDs() error {
+	if server.lastIDs != nil {
+		return nil
+	}
+	lastIDs := make(map[int64]int64)
+	if server.Store != nil {
+		saved, err := server.Store.LoadLastIDs()
+		if err != nil {
+			return fmt.Errorf("vkbot: load polling last ids: %w", err)
+		}
+		for peerID, id := range saved {
+			lastIDs[peerID] = id
+		}
+	}
+	server.lastIDs = lastIDs
+	return nil
+}
+
+// FilterReadMesages - messages already filtered by last processed ids
+func (server *PollingServer) FilterReadMesages(messages []*Message) []*Message {
+	return messages
+}
+
+// FilePollingStore - last processed ids storage in JSON file. Survives bot restarts
+type FilePollingStore struct {
+	path string
+}
+
+// NewFilePollingStore - create file last processed ids storage
+func NewFilePollingStore(path string) *FilePollingStore {
+	return &FilePollingStore{path: path}
+}
+
+// LoadLastIDs - load last processed ids, empty if file not exists
+func (s *FilePollingStore) LoadLastIDs() (map[int64]int64, error) {
+	lastIDs := make(map[int64]int64)
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lastIDs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &lastIDs); err != nil {
+			return nil, err
+		}
+	}
+	return lastIDs, nil
+}
+
+// SaveLastIDs - save last processed ids
+func (s *FilePollingStore) SaveLastIDs(lastIDs map[int64]int64) error {
+	return writeJSONFile(s.path, lastIDs)
+}
@@ -0,0 +1,86 @@
+package govkbot
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPollingServer_GetMessages(t *testing.T) {
+	SetAPI("", "test", "")
+	server := NewPollingServer(API, 0)
+	messages, err := server.GetMessages()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	// both mock conversations return same history
+	if len(messages) != 4 || messages[0].Body != "first" || messages[1].Body != "first" {
+		t.Fatalf("wrong messages: %+v", messages)
+	}
+	messages, err = server.GetMessages()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(messages) != 0 {
+		t.Errorf("processed messages returned again: %+v", messages)
+	}
+}
+
+func TestPollingServer_Poll(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	bot.HandleError(errorHandler)
+	count := 0
+	bot.Handle("first", func(c *Context) error {
+		count++
+		return nil
+	})
+	n, err := bot.Poll(NewPollingServer(API, 0))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != 4 || count != 2 {
+		t.Errorf("wrong routed messages: %d %d", n, count)
+	}
+}
+
+func TestVKBot_ListenPollingStop(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	bot.HandleError(errorHandler)
+	done := make(chan error)
+	go func() { done <- bot.ListenPolling(API, time.Hour) }()
+	// first poll returns mock messages, next one waits interval
+	time.Sleep(50 * time.Millisecond)
+	bot.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("polling wait not interrupted by Stop")
+	}
+	if NewPollingServer(API, 0).MarkRead {
+		t.Error("conversations marked as read by default")
+	}
+}
+
+func TestPollingServer_Store(t *testing.T) {
+	SetAPI("", "test", "")
+	store := NewFilePollingStore(filepath.Join(t.TempDir(), "polling.json"))
+	server := NewPollingServer(API, 0)
+	server.Store = store
+	messages, err := server.GetMessages()
+	if err != nil || len(messages) != 4 {
+		t.Fatalf("wrong messages: %+v %v", messages, err)
+	}
+
+	// restarted bot loads last processed ids and skips routed messages
+	server = NewPollingServer(API, 0)
+	server.Store = store
+	messages, err = server.GetMessages()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(messages) != 0 {
+		t.Errorf("processed messages returned after restart: %+v", messages)
+	}
+}
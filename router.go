@@ -1,7 +1,9 @@
 package govkbot
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"time"
 )
 
 const (
@@ -55,21 +57,42 @@ func SetAPI(token string, url string, ver string) {
 	}
 }
 
+// SetCache - enable cache of API lookups for ttl. Zero ttl disables cache
+func SetCache(ttl time.Duration, size int) {
+	API.SetCache(ttl, size)
+}
+
 // SetLang - sets VK response language. Default auto. Available: en, ru, ua, be, es, fi, de, it
 func SetLang(lang string) {
 	API.Lang = lang
 }
 
+// Handle - add substr message handler with context.
+// Handler can add replies by c.Reply and return error to pass it to error handler
+func Handle(command string, handler HandlerFunc, options ...RouteOption) {
+	Bot.Handle(command, handler, options...)
+}
+
+// Use - add middlewares for message handlers
+func Use(middlewares ...Middleware) {
+	Bot.Use(middlewares...)
+}
+
+// HandleDialog - add dialog started by command
+func HandleDialog(command string, d *Dialog, options ...RouteOption) {
+	Bot.HandleDialog(command, d, options...)
+}
+
 // HandleMessage - add substr message handler.
 // Function must return string to reply or "" (if no reply)
-func HandleMessage(command string, handler func(*Message) string) {
-	Bot.HandleMessage(command, handler)
+func HandleMessage(command string, handler func(*Message) string, options ...RouteOption) {
+	Bot.HandleMessage(command, handler, options...)
 }
 
 // HandleAdvancedMessage - add substr message handler.
 // Function must return string to reply or "" (if no reply)
-func HandleAdvancedMessage(command string, handler func(*Message) Reply) {
-	Bot.HandleAdvancedMessage(command, handler)
+func HandleAdvancedMessage(command string, handler func(*Message) Reply, options ...RouteOption) {
+	Bot.HandleAdvancedMessage(command, handler, options...)
 }
 
 // HandleAction - add action handler.
@@ -83,15 +106,6 @@ func HandleError(handler func(*Message, error)) {
 	Bot.HandleError(handler)
 }
 
-func sendError(msg *Message, err error) {
-	if Bot.errorHandler != nil {
-		Bot.errorHandler(msg, err)
-	} else {
-		log.Fatalf("VKBot error: %+v\n", err.Error())
-	}
-
-}
-
 // Listen - start server
 func Listen(token string, url string, ver string, adminID int64) error {
 	if API.Token == "" {
@@ -104,6 +118,90 @@ func Listen(token string, url string, ver string, adminID int64) error {
 	return Bot.ListenUser(API)
 }
 
+// ListenPolling - listen by polling unread conversations, if long poll is blocked
+func ListenPolling(token string, url string, ver string, adminID int64, interval time.Duration) error {
+	if API.Token == "" {
+		SetAPI(token, url, ver)
+	}
+	API.AdminID = adminID
+	return Bot.ListenPolling(API, interval)
+}
+
+// SetWorkers - process messages in parallel by workers count
+func SetWorkers(workers int, queueSize int) {
+	Bot.SetWorkers(workers, queueSize)
+}
+
+// SetPollOptions - set long poll loop options
+func SetPollOptions(options PollOptions) {
+	Bot.SetPollOptions(options)
+}
+
+// SetThrottle - enable inbound commands throttling
+func SetThrottle(options ThrottleOptions) {
+	Bot.SetThrottle(options)
+}
+
+// SetAccessDeniedMessage - set reply for commands not allowed to user
+func SetAccessDeniedMessage(msg string) {
+	Bot.SetAccessDeniedMessage(msg)
+}
+
+// SetMentionOnly - in group chats route only messages addressed to bot
+func SetMentionOnly(mentionOnly bool) {
+	Bot.SetMentionOnly(mentionOnly)
+}
+
+// SetLogger - set library logger of API and bot
+func SetLogger(logger *slog.Logger) {
+	API.Logger = logger
+}
+
+// SetTypingThreshold - show "typing" while handler runs longer than threshold
+func SetTypingThreshold(threshold time.Duration) {
+	Bot.SetTypingThreshold(threshold)
+}
+
+// Broadcast - send reply to all peers
+func Broadcast(ctx context.Context, peers PeerIterator, reply Reply, options BroadcastOptions) (BroadcastProgress, error) {
+	return Bot.Broadcast(ctx, peers, reply, options)
+}
+
+// At - send reply to peer at time
+func At(at time.Time, peerID int64, reply Reply) (string, error) {
+	return Bot.At(at, peerID, reply)
+}
+
+// After - send reply to peer after delay
+func After(delay time.Duration, peerID int64, reply Reply) (string, error) {
+	return Bot.After(delay, peerID, reply)
+}
+
+// Every - send reply to peer by cron spec
+func Every(spec string, peerID int64, reply Reply) (string, error) {
+	return Bot.Every(spec, peerID, reply)
+}
+
+// CancelJob - cancel scheduled job
+func CancelJob(id string) error {
+	return Bot.CancelJob(id)
+}
+
+// SetJobStore - set scheduled jobs storage
+func SetJobStore(store JobStore) error {
+	return Bot.SetJobStore(store)
+}
+
+// SetStopTimeout - set time for messages in progress to finish after Stop
+func SetStopTimeout(timeout time.Duration) {
+	Bot.SetStopTimeout(timeout)
+}
+
+// Stop - stop listening
+func Stop() {
+	Bot.Stop()
+}
+
 // NotifyAdmin - notify AdminID by VK
 func NotifyAdmin(msg string) error {
 	return API.NotifyAdmin(msg)
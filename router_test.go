@@ -118,7 +118,7 @@ func TestRouteMessages(t *testing.T) {
 
 func TestGetMessages(t *testing.T) {
 	SetAPI("", "test", "")
-	messages, err := Bot.GetMessages()
+	messages, err := API.NewBot().GetMessages()
 	if err != nil {
 		t.Error(err.Error())
 	}
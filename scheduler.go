@@ -0,0 +1,365 @@
+package govkbot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrJobNotFound - job with id not scheduled
+var ErrJobNotFound = errors.New("vkbot: job not found")
+
+// Job - scheduled message. Cron is empty for one-shot jobs
+type Job struct {
+	ID     string    `json:"id"`
+	PeerID int64     `json:"peer_id"`
+	Reply  Reply     `json:"reply"`
+	At     time.Time `json:"at"` // next run time
+	Cron   string    `json:"cron,omitempty"`
+}
+
+// JobStore - scheduled jobs storage
+type JobStore interface {
+	LoadJobs() ([]*Job, error)
+	SaveJob(job *Job) error
+	DeleteJob(id string) error
+}
+
+type scheduler struct {
+	mu      sync.Mutex
+	store   JobStore
+	jobs    map[string]*Job
+	wake    chan struct{}
+	running bool
+}
+
+func newScheduler(store JobStore) *scheduler {
+	return &scheduler{
+		store: store,
+		jobs:  make(map[string]*Job),
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// SetJobStore - set jobs storage and load saved jobs. Jobs scheduled before are moved to new storage.
+// Default is memory storage
+func (bot *VKBot) SetJobStore(store JobStore) error {
+	jobs, err := store.LoadJobs()
+	if err != nil {
+		return err
+	}
+	if err = bot.scheduler.setStore(store, jobs); err != nil {
+		return err
+	}
+	if len(bot.Jobs()) > 0 {
+		bot.startScheduler()
+	}
+	return nil
+}
+
+func (s *scheduler) setStore(store JobStore, jobs []*Job) error {
+	s.mu.Lock()
+	defer s.notify()
+	defer s.mu.Unlock()
+	loaded := make(map[string]*Job, len(jobs)+len(s.jobs))
+	for _, job := range jobs {
+		loaded[job.ID] = job
+	}
+	for id, job := range s.jobs {
+		if _, ok := loaded[id]; ok {
+			continue
+		}
+		if err := store.SaveJob(job); err != nil {
+			return fmt.Errorf("vkbot: move job %s to new store: %w", id, err)
+		}
+		loaded[id] = job
+	}
+	s.store = store
+	s.jobs = loaded
+	return nil
+}
+
+// At - send reply to peer at time. Returns job id.
+// Scheduler runs until bot stopped, it doesn't need Listen
+func (bot *VKBot) At(at time.Time, peerID int64, reply Reply) (string, error) {
+	return bot.addJob(&Job{PeerID: peerID, Reply: reply, At: at})
+}
+
+// After - send reply to peer after delay. Returns job id
+func (bot *VKBot) After(delay time.Duration, peerID int64, reply Reply) (string, error) {
+	return bot.At(time.Now().Add(delay), peerID, reply)
+}
+
+// Every - send reply to peer by cron spec "minute hour day month weekday" in local time,
+// e.g. "0 9 * * 1-5" - at 9:00 on weekdays. Returns job id
+func (bot *VKBot) Every(spec string, peerID int64, reply Reply) (string, error) {
+	schedule, err := parseCron(spec)
+	if err != nil {
+		return "", err
+	}
+	next := schedule.Next(time.Now())
+	if next.IsZero() {
+		return "", fmt.Errorf("vkbot: cron spec %q never runs", spec)
+	}
+	return bot.addJob(&Job{PeerID: peerID, Reply: reply, At: next, Cron: spec})
+}
+
+func (bot *VKBot) addJob(job *Job) (string, error) {
+	id, err := bot.scheduler.add(job)
+	if err != nil {
+		return "", err
+	}
+	bot.startScheduler()
+	return id, nil
+}
+
+// CancelJob - cancel scheduled job
+func (bot *VKBot) CancelJob(id string) error {
+	s := bot.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[id]; !ok {
+		return ErrJobNotFound
+	}
+	delete(s.jobs, id)
+	return s.store.DeleteJob(id)
+}
+
+// Jobs - returns scheduled jobs sorted by run time
+func (bot *VKBot) Jobs() []Job {
+	s := bot.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].At.Before(jobs[j].At) })
+	return jobs
+}
+
+func (s *scheduler) add(job *Job) (string, error) {
+	id, err := newJobID()
+	if err != nil {
+		return "", err
+	}
+	job.ID = id
+	s.mu.Lock()
+	err = s.store.SaveJob(job)
+	if err == nil {
+		s.jobs[id] = job
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	s.notify()
+	return id, nil
+}
+
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// due - returns jobs to run now and delay to next job
+func (s *scheduler) due(now time.Time) ([]*Job, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var jobs []*Job
+	wait := time.Hour
+	for _, job := range s.jobs {
+		if !job.At.After(now) {
+			jobs = append(jobs, job)
+		} else if d := job.At.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].At.Before(jobs[j].At) })
+	return jobs, wait
+}
+
+// done - reschedules recurring job or deletes one-shot job after run
+func (s *scheduler) done(job *Job, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.ID]; !ok {
+		return nil
+	}
+	if job.Cron != "" {
+		if schedule, err := parseCron(job.Cron); err == nil {
+			if next := schedule.Next(now); !next.IsZero() {
+				job.At = next
+				return s.store.SaveJob(job)
+			}
+		}
+	}
+	delete(s.jobs, job.ID)
+	return s.store.DeleteJob(job.ID)
+}
+
+// startScheduler - run scheduler in background if it is not running and bot not stopped
+func (bot *VKBot) startScheduler() {
+	s := bot.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running || bot.stopped() {
+		return
+	}
+	s.running = true
+	go bot.runScheduler()
+}
+
+// runScheduler - send scheduled jobs until bot stopped
+func (bot *VKBot) runScheduler() {
+	s := bot.scheduler
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	for {
+		jobs, wait := s.due(time.Now())
+		for _, job := range jobs {
+			if _, err := bot.Reply(&Message{PeerID: job.PeerID}, job.Reply); err != nil {
+				bot.jobError(job, fmt.Errorf("vkbot: scheduled job %s to peer %d: %w", job.ID, job.PeerID, err))
+			}
+			if err := s.done(job, time.Now()); err != nil {
+				bot.jobError(job, fmt.Errorf("vkbot: reschedule job %s: %w", job.ID, err))
+			}
+		}
+		if len(jobs) > 0 {
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-bot.ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// jobError - pass job error to error handler or log it. Scheduler keeps running
+func (bot *VKBot) jobError(job *Job, err error) {
+	if bot.errorHandler != nil {
+		bot.errorHandler(nil, err)
+		return
+	}
+	bot.log().Error("scheduled job failed", "job_id", job.ID, "peer_id", job.PeerID, "error", err)
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// MemoryJobStore - in memory jobs storage
+type MemoryJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+// NewMemoryJobStore - create memory jobs storage
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{jobs: make(map[string]Job)}
+}
+
+// LoadJobs - load all jobs
+func (s *MemoryJobStore) LoadJobs() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		job := job
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
+}
+
+// SaveJob - save job
+func (s *MemoryJobStore) SaveJob(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = *job
+	return nil
+}
+
+// DeleteJob - delete job
+func (s *MemoryJobStore) DeleteJob(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+// FileJobStore - jobs storage in JSON file. Pending jobs survive bot restarts
+type FileJobStore struct {
+	mu   sync.Mutex
+	path string
+	jobs map[string]Job
+}
+
+// NewFileJobStore - create file jobs storage and load saved jobs
+func NewFileJobStore(path string) (*FileJobStore, error) {
+	s := &FileJobStore{path: path, jobs: make(map[string]Job)}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &s.jobs); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// LoadJobs - load all jobs
+func (s *FileJobStore) LoadJobs() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		job := job
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
+}
+
+// SaveJob - save job
+func (s *FileJobStore) SaveJob(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = *job
+	return writeJSONFile(s.path, s.jobs)
+}
+
+// DeleteJob - delete job
+func (s *FileJobStore) DeleteJob(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[id]; !ok {
+		return nil
+	}
+	delete(s.jobs, id)
+	return writeJSONFile(s.path, s.jobs)
+}
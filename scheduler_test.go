@@ -0,0 +1,131 @@
+package govkbot
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVKBot_Scheduler(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	bot.HandleError(errorHandler)
+	id, err := bot.After(time.Hour, 1, Reply{Msg: "later"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err = bot.Every("0 9 * * *", 1, Reply{Msg: "daily"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err = bot.Every("wrong", 1, Reply{Msg: "daily"}); err == nil {
+		t.Error("error expected")
+	}
+	if err = bot.CancelJob(id); err != nil {
+		t.Error(err.Error())
+	}
+	if err = bot.CancelJob(id); err != ErrJobNotFound {
+		t.Errorf("wrong error: %+v", err)
+	}
+
+	if _, err = bot.After(0, 1, Reply{Msg: "now"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(bot.Jobs()) != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	bot.Stop()
+	jobs := bot.Jobs()
+	if len(jobs) != 1 || jobs[0].Cron == "" || jobs[0].At.Hour() != 9 {
+		t.Errorf("wrong jobs: %+v", jobs)
+	}
+}
+
+func TestFileJobStore(t *testing.T) {
+	SetAPI("", "test", "")
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	store, err := NewFileJobStore(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	bot := API.NewBot()
+	if err = bot.SetJobStore(store); err != nil {
+		t.Fatal(err.Error())
+	}
+	id, err := bot.After(time.Hour, 1, Reply{Msg: "reminder", Keyboard: &Keyboard{OneTime: true}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	store, err = NewFileJobStore(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	bot = API.NewBot()
+	if err = bot.SetJobStore(store); err != nil {
+		t.Fatal(err.Error())
+	}
+	jobs := bot.Jobs()
+	if len(jobs) != 1 || jobs[0].ID != id || jobs[0].Reply.Msg != "reminder" || jobs[0].Reply.Keyboard == nil {
+		t.Fatalf("wrong jobs: %+v", jobs)
+	}
+	if err = bot.CancelJob(id); err != nil {
+		t.Error(err.Error())
+	}
+	if jobs, _ := store.LoadJobs(); len(jobs) != 0 {
+		t.Errorf("job not deleted: %+v", jobs)
+	}
+}
+
+func TestVKBot_SetJobStoreMovesJobs(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	id, err := bot.After(time.Hour, 1, Reply{Msg: "reminder"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	store, err := NewFileJobStore(filepath.Join(t.TempDir(), "jobs.json"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err = bot.SetJobStore(store); err != nil {
+		t.Fatal(err.Error())
+	}
+	if jobs := bot.Jobs(); len(jobs) != 1 || jobs[0].ID != id {
+		t.Errorf("scheduled job dropped: %+v", jobs)
+	}
+	if jobs, _ := store.LoadJobs(); len(jobs) != 1 || jobs[0].ID != id {
+		t.Errorf("scheduled job not saved to new store: %+v", jobs)
+	}
+}
+
+func TestVKBot_SchedulerJobError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":{"error_code":901,"error_msg":"Can't send messages"}}`))
+	}))
+	defer srv.Close()
+	bot := (&VkAPI{Token: "token", URL: srv.URL + "/method/", GroupID: 1}).NewBot()
+	var logs bytes.Buffer
+	bot.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer bot.Stop()
+
+	// scheduler runs without Listen, failed job is logged without exit
+	if _, err := bot.After(0, 1, Reply{Msg: "now"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(bot.Jobs()) != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(bot.Jobs()) != 0 {
+		t.Fatal("job not run")
+	}
+	if !strings.Contains(logs.String(), "scheduled job failed") {
+		t.Errorf("job error not logged: %s", logs.String())
+	}
+}
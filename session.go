@@ -0,0 +1,195 @@
+package govkbot
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultSessionSize - default max entries count of memory session store
+const DefaultSessionSize = 10000
+
+// SessionStore - sessions key-value storage
+type SessionStore interface {
+	Get(key string) (value []byte, ok bool, err error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// Session - values of one user or one peer. Values stored JSON encoded
+type Session struct {
+	store  SessionStore
+	prefix string
+}
+
+// UserSession - returns session of user
+func (bot *VKBot) UserSession(userID int64) *Session {
+	return &Session{store: bot.sessionStore, prefix: "user:" + strconv.FormatInt(userID, 10) + ":"}
+}
+
+// PeerSession - returns session of peer (chat or private dialog)
+func (bot *VKBot) PeerSession(peerID int64) *Session {
+	return &Session{store: bot.sessionStore, prefix: "peer:" + strconv.FormatInt(peerID, 10) + ":"}
+}
+
+// SetSessionStore - set sessions storage. Default is memory store
+func (bot *VKBot) SetSessionStore(store SessionStore) {
+	bot.sessionStore = store
+}
+
+// UserSession - returns session of message author
+func (c *Context) UserSession() *Session {
+	return c.Bot.UserSession(c.Message.UserID)
+}
+
+// PeerSession - returns session of message peer
+func (c *Context) PeerSession() *Session {
+	return c.Bot.PeerSession(c.Message.PeerID)
+}
+
+// Get - decode session value to v. Returns false if value not exists or expired
+func (s *Session) Get(name string, v interface{}) (bool, error) {
+	value, ok, err := s.store.Get(s.prefix + name)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, json.Unmarshal(value, v)
+}
+
+// Set - set session value. Zero ttl means no expiration
+func (s *Session) Set(name string, v interface{}, ttl time.Duration) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(s.prefix+name, value, ttl)
+}
+
+// Delete - delete session value
+func (s *Session) Delete(name string) error {
+	return s.store.Delete(s.prefix + name)
+}
+
+// GetString - returns session string value or ""
+func (s *Session) GetString(name string) string {
+	v := ""
+	s.Get(name, &v)
+	return v
+}
+
+// GetInt - returns session int value or 0
+func (s *Session) GetInt(name string) int64 {
+	var v int64
+	s.Get(name, &v)
+	return v
+}
+
+// MemorySessionStore - in memory sessions store with LRU eviction
+type MemorySessionStore struct {
+	cache *lruCache
+}
+
+// NewMemorySessionStore - create memory sessions store with max entries count
+func NewMemorySessionStore(size int) *MemorySessionStore {
+	return &MemorySessionStore{cache: newLRUCache(size)}
+}
+
+// Get - get value
+func (s *MemorySessionStore) Get(key string) ([]byte, bool, error) {
+	value, ok := s.cache.Get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	return value.([]byte), true, nil
+}
+
+// Set - set value
+func (s *MemorySessionStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.cache.Set(key, value, ttl)
+	return nil
+}
+
+// Delete - delete value
+func (s *MemorySessionStore) Delete(key string) error {
+	s.cache.Delete(key)
+	return nil
+}
+
+// FileSessionStore - sessions store in JSON file. Survives bot restarts
+type FileSessionStore struct {
+	mu    sync.Mutex
+	path  string
+	items map[string]fileSessionItem
+}
+
+type fileSessionItem struct {
+	Value   json.RawMessage `json:"value"`
+	Expires time.Time       `json:"expires,omitempty"`
+}
+
+func (i fileSessionItem) expired() bool {
+	return !i.Expires.IsZero() && time.Now().After(i.Expires)
+}
+
+// NewFileSessionStore - create file sessions store and load saved values
+func NewFileSessionStore(path string) (*FileSessionStore, error) {
+	s := &FileSessionStore{path: path, items: make(map[string]fileSessionItem)}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &s.items); err != nil {
+			return nil, err
+		}
+	}
+	for key, item := range s.items {
+		if item.expired() {
+			delete(s.items, key)
+		}
+	}
+	return s, nil
+}
+
+// Get - get value
+func (s *FileSessionStore) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[key]
+	if !ok || item.expired() {
+		return nil, false, nil
+	}
+	return item.Value, true, nil
+}
+
+// Set - set value. Value must be valid JSON
+func (s *FileSessionStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !json.Valid(value) {
+		return errors.New("vkbot: session value is not json")
+	}
+	item := fileSessionItem{Value: value}
+	if ttl > 0 {
+		item.Expires = time.Now().Add(ttl)
+	}
+	s.items[key] = item
+	return writeJSONFile(s.path, s.items)
+}
+
+// Delete - delete value
+func (s *FileSessionStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[key]; !ok {
+		return nil
+	}
+	delete(s.items, key)
+	return writeJSONFile(s.path, s.items)
+}
@@ -0,0 +1,79 @@
+package govkbot
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSession_Values(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	bot.Handle("/count", func(c *Context) error {
+		return c.UserSession().Set("count", c.UserSession().GetInt("count")+1, 0)
+	})
+	for i := 0; i < 3; i++ {
+		bot.RouteMessage(&Message{Body: "/count", PeerID: 2, UserID: 1})
+	}
+	if bot.UserSession(1).GetInt("count") != 3 {
+		t.Error(wrongValueReturned)
+	}
+	if bot.PeerSession(1).GetInt("count") != 0 {
+		t.Error("peer and user sessions must be separated")
+	}
+
+	s := bot.PeerSession(2)
+	s.Set("name", "chat", time.Millisecond)
+	if s.GetString("name") != "chat" {
+		t.Error(wrongValueReturned)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if s.GetString("name") != "" {
+		t.Error("value not expired")
+	}
+	s.Set("name", "chat", 0)
+	s.Delete("name")
+	if ok, _ := s.Get("name", new(string)); ok {
+		t.Error("value not deleted")
+	}
+}
+
+func TestMemorySessionStore_LRU(t *testing.T) {
+	s := NewMemorySessionStore(2)
+	s.Set("a", []byte("1"), 0)
+	s.Set("b", []byte("2"), 0)
+	s.Get("a")
+	s.Set("c", []byte("3"), 0)
+	if _, ok, _ := s.Get("b"); ok {
+		t.Error("least recently used value not evicted")
+	}
+	if _, ok, _ := s.Get("a"); !ok {
+		t.Error("recently used value evicted")
+	}
+}
+
+func TestFileSessionStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	store, err := NewFileSessionStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Session{store: store, prefix: "user:1:"}
+	if err = s.Set("phone", "123", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Set("old", "1", time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	store, err = NewFileSessionStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = &Session{store: store, prefix: "user:1:"}
+	if s.GetString("phone") != "123" {
+		t.Error("value not loaded")
+	}
+	if s.GetString("old") != "" {
+		t.Error("expired value loaded")
+	}
+}
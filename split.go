@@ -0,0 +1,55 @@
+package govkbot
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxMessageLength - max characters in one message, longer messages are split
+const MaxMessageLength = 4096
+
+// SanitizeMessage - replaces invalid UTF-8 sequences, VK rejects such messages
+func SanitizeMessage(text string) string {
+	return strings.ToValidUTF8(text, "�")
+}
+
+// SplitMessage - split text to parts not longer than limit characters.
+// Text is split by paragraphs, then by lines, then by spaces, then hard cut
+func SplitMessage(text string, limit int) []string {
+	if limit <= 0 {
+		limit = MaxMessageLength
+	}
+	var parts []string
+	for utf8.RuneCountInString(text) > limit {
+		cut := runeOffset(text, limit)
+		head := text[:cut]
+		i := -1
+		for _, sep := range []string{"\n\n", "\n", " "} {
+			if i = strings.LastIndex(head, sep); i > 0 {
+				break
+			}
+		}
+		if i <= 0 {
+			i = cut
+		}
+		if part := strings.TrimRight(text[:i], " \n"); part != "" {
+			parts = append(parts, part)
+		}
+		text = strings.TrimLeft(text[i:], " \n")
+	}
+	if text != "" || len(parts) == 0 {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+// runeOffset - byte offset of n-th rune
+func runeOffset(text string, n int) int {
+	for i := range text {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(text)
+}
@@ -0,0 +1,56 @@
+package govkbot
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	cases := []struct {
+		text  string
+		limit int
+		parts []string
+	}{
+		{"", 10, []string{""}},
+		{"short", 10, []string{"short"}},
+		{"first par\n\nsecond par", 12, []string{"first par", "second par"}},
+		{"line one\nline two", 12, []string{"line one", "line two"}},
+		{"word word word", 10, []string{"word word", "word"}},
+		{"abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"пример текста", 7, []string{"пример", "текста"}},
+	}
+	for _, c := range cases {
+		parts := SplitMessage(c.text, c.limit)
+		if strings.Join(parts, "|") != strings.Join(c.parts, "|") {
+			t.Errorf("wrong split of %q: %q", c.text, parts)
+		}
+	}
+	long := strings.Repeat("слово ", 2000)
+	for _, part := range SplitMessage(long, 0) {
+		if utf8.RuneCountInString(part) > MaxMessageLength {
+			t.Errorf("too long part: %d", utf8.RuneCountInString(part))
+		}
+	}
+}
+
+func TestSanitizeMessage(t *testing.T) {
+	if s := SanitizeMessage("ok\xffok"); !utf8.ValidString(s) || s != "ok�ok" {
+		t.Errorf("wrong sanitized message: %q", s)
+	}
+}
+
+func TestVkAPI_SendLongMessage(t *testing.T) {
+	SetAPI("", "test", "")
+	kb := &Keyboard{}
+	id, err := API.SendAdvancedPeerMessage(1, Reply{Msg: strings.Repeat("a\n", 5000), Keyboard: kb})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if id == 0 {
+		t.Error(wrongValueReturned)
+	}
+	if _, err = API.SendMessage(1, strings.Repeat("b ", 5000)); err != nil {
+		t.Error(err.Error())
+	}
+}
@@ -1,13 +1,21 @@
 package govkbot
 
 import (
+	"encoding/json"
 	"strings"
 )
 
-// Mention - user mention in message
+// Mention - user or group mention in message.
+// Type and ID are empty for @screen_name mentions, ScreenName is empty for id mentions
 type Mention struct {
-	ID   int64
-	Name string
+	ID         int64
+	Name       string
+	Type       string
+	ScreenName string
+	Offset     int // bytes
+	Length     int // bytes
+	RuneOffset int
+	RuneLength int
 }
 
 // Button for keyboard, which sends to user
@@ -16,6 +24,7 @@ type Button struct {
 		Type    string `json:"type"`
 		Payload string `json:"payload"`
 		Label   string `json:"label"`
+		Link    string `json:"link,omitempty"`
 	} `json:"action"`
 	Color string `json:"color"`
 }
@@ -23,32 +32,156 @@ type Button struct {
 // Keyboard to send for user
 type Keyboard struct {
 	OneTime bool       `json:"one_time"`
+	Inline  bool       `json:"inline,omitempty"`
 	Buttons [][]Button `json:"buttons"`
 }
 
+// JSON - returns keyboard encoded for VK API
+func (k *Keyboard) JSON() (string, error) {
+	b, err := json.Marshal(k)
+	return string(b), err
+}
+
 // Reply for message
 type Reply struct {
 	Msg      string
 	Keyboard *Keyboard
+	Template *Carousel
 }
 
 // Message - VK message struct
 type Message struct {
-	ID          int64
-	Date        int
-	Out         int
-	UserID      int64 `json:"user_id"`
-	ChatID      int64 `json:"chat_id"`
-	PeerID      int64 `json:"peer_id"`
-	ReadState   int   `json:"read_state"`
-	Title       string
-	Body        string
-	Action      string
-	ActionMID   int64 `json:"action_mid"`
-	Flags       int
-	Timestamp   int64
-	Payload     string
-	FwdMessages []Message `json:"fwd_messages"`
+	ID                    int64
+	Date                  int
+	Out                   int
+	UserID                int64 `json:"user_id"`
+	ChatID                int64 `json:"chat_id"`
+	PeerID                int64 `json:"peer_id"`
+	ReadState             int   `json:"read_state"`
+	Title                 string
+	Body                  string
+	Action                string
+	ActionMID             int64 `json:"action_mid"`
+	Flags                 int
+	Timestamp             int64
+	Payload               string
+	FwdMessages           []Message   `json:"fwd_messages"`
+	ReplyMessage          *Message    `json:"reply_message"`
+	ConversationMessageID int64       `json:"conversation_message_id"`
+	ClientInfo            *ClientInfo `json:"client_info"` // nil if client features unknown
+}
+
+// ClientInfo - features supported by user client, sent with new messages to group bots
+type ClientInfo struct {
+	ButtonActions  []string `json:"button_actions"`
+	Keyboard       bool     `json:"keyboard"`
+	InlineKeyboard bool     `json:"inline_keyboard"`
+	Carousel       bool     `json:"carousel"`
+	LangID         int      `json:"lang_id"`
+}
+
+// SupportsAction - client supports button action type (text, callback, open_link...)
+func (c *ClientInfo) SupportsAction(action string) bool {
+	if c == nil {
+		return false
+	}
+	for _, a := range c.ButtonActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// IsChat - message sent to group chat
+func (m Message) IsChat() bool {
+	return m.PeerID > ChatPrefix
+}
+
+// peer - returns peer id, restored from chat or user id for old messages
+func (m Message) peer() int64 {
+	switch {
+	case m.PeerID != 0:
+		return m.PeerID
+	case m.ChatID != 0 && m.ChatID < ChatPrefix:
+		return ChatPrefix + m.ChatID
+	case m.ChatID != 0:
+		return m.ChatID
+	}
+	return m.UserID
+}
+
+// SentMessage - ids of sent message. Error is set for failed peer in peer_ids mode
+type SentMessage struct {
+	PeerID                int64      `json:"peer_id"`
+	MessageID             int64      `json:"message_id"`
+	ConversationMessageID int64      `json:"conversation_message_id"`
+	Error                 *SendError `json:"error"`
+}
+
+// SendError - error of message sending to one peer
+type SendError struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+}
+
+func (err *SendError) Error() string {
+	return "vk: " + err.Description
+}
+
+// APIMessage - message object of VK API
+type APIMessage struct {
+	ID                    int64  `json:"id"`
+	Date                  int    `json:"date"`
+	PeerID                int64  `json:"peer_id"`
+	FromID                int64  `json:"from_id"`
+	Out                   int    `json:"out"`
+	Text                  string `json:"text"`
+	ConversationMessageID int64  `json:"conversation_message_id"`
+	Payload               string `json:"payload"`
+	Action                *struct {
+		Type     string `json:"type"`
+		MemberID int64  `json:"member_id"`
+	} `json:"action"`
+	FwdMessages  []APIMessage `json:"fwd_messages"`
+	ReplyMessage *APIMessage  `json:"reply_message"`
+}
+
+// Message - convert API message to bot message
+func (m APIMessage) Message() *Message {
+	msg := Message{
+		ID:                    m.ID,
+		Date:                  m.Date,
+		Out:                   m.Out,
+		UserID:                m.FromID,
+		PeerID:                m.PeerID,
+		Body:                  m.Text,
+		Payload:               m.Payload,
+		ConversationMessageID: m.ConversationMessageID,
+	}
+	if m.PeerID != m.FromID {
+		msg.ChatID = m.PeerID
+	}
+	if m.Action != nil {
+		msg.Action = m.Action.Type
+		msg.ActionMID = m.Action.MemberID
+	}
+	for _, fwd := range m.FwdMessages {
+		msg.FwdMessages = append(msg.FwdMessages, *fwd.Message())
+	}
+	if m.ReplyMessage != nil {
+		msg.ReplyMessage = m.ReplyMessage.Message()
+	}
+	return &msg
+}
+
+// APIMessagesResponse - VK API messages list response
+type APIMessagesResponse struct {
+	Response struct {
+		Count int
+		Items []APIMessage
+	}
+	Error *VKError
 }
 
 // Messages - VK Messages
@@ -92,6 +225,8 @@ type User struct {
 	Deactivated     string `json:"deactivated"`
 	IsAdmin         bool   `json:"is_admin"`
 	IsOwner         bool   `json:"is_owner"`
+
+	Raw json.RawMessage `json:"-"` // user JSON with all requested fields, see Field
 }
 
 // FullName - returns full name of user
@@ -179,6 +314,12 @@ type FriendDeleteResponse struct {
 	Error    *VKError
 }
 
+// RawResponse - response to parse later
+type RawResponse struct {
+	Response json.RawMessage
+	Error    *VKError
+}
+
 // SimpleResponse - simple int response
 type SimpleResponse struct {
 	Response int64
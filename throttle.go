@@ -0,0 +1,197 @@
+package govkbot
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ThrottleAction - what to do with message over rate limit
+type ThrottleAction int
+
+const (
+	// ThrottleIgnore - silently ignore message
+	ThrottleIgnore ThrottleAction = iota
+	// ThrottleWarn - reply warning once per limit interval, then ignore
+	ThrottleWarn
+	// ThrottleHook - call hook, hook replies are sent
+	ThrottleHook
+)
+
+// Throttle limit kinds
+const (
+	ThrottleLimitUser     = "user"
+	ThrottleLimitPeer     = "peer"
+	ThrottleLimitCooldown = "cooldown"
+)
+
+// DefaultThrottleWarning - default warning for ThrottleWarn action
+const DefaultThrottleWarning = "Too many requests, please wait"
+
+// RateLimit - max messages count per interval. Zero count means no limit
+type RateLimit struct {
+	Count    int
+	Interval time.Duration
+}
+
+// ThrottleOptions - inbound commands rate limits
+type ThrottleOptions struct {
+	User      RateLimit                // commands of one user in all peers
+	Peer      RateLimit                // commands of all users in one peer
+	Cooldowns map[string]time.Duration // min interval between same command of one user
+	Action    ThrottleAction
+	Warning   string
+	Hook      func(m *Message, info ThrottleInfo) []Reply
+}
+
+// ThrottleInfo - info about exceeded limit
+type ThrottleInfo struct {
+	Limit      string
+	Command    string
+	RetryAfter time.Duration
+}
+
+type throttler struct {
+	mu        sync.Mutex
+	options   ThrottleOptions
+	hits      map[string][]time.Time
+	cooldowns map[string]time.Time
+	warned    map[string]time.Time
+	lastSweep time.Time
+}
+
+// SetThrottle - enable inbound commands throttling. Checked before handlers run
+func (bot *VKBot) SetThrottle(options ThrottleOptions) {
+	if options.Warning == "" {
+		options.Warning = DefaultThrottleWarning
+	}
+	bot.throttler = &throttler{
+		options:   options,
+		hits:      make(map[string][]time.Time),
+		cooldowns: make(map[string]time.Time),
+		warned:    make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// check - check limits for message and matched commands. Returns false and replies if message throttled
+func (t *throttler) check(m *Message, commands []string) (bool, []Reply) {
+	t.mu.Lock()
+	now := time.Now()
+	t.sweep(now)
+	info, key, ok := t.allow(m, commands, now)
+	warn := false
+	if !ok && t.options.Action == ThrottleWarn {
+		if until, warned := t.warned[key]; !warned || now.After(until) {
+			t.warned[key] = now.Add(info.RetryAfter)
+			warn = true
+		}
+	}
+	t.mu.Unlock()
+
+	if ok {
+		return true, nil
+	}
+	switch t.options.Action {
+	case ThrottleWarn:
+		if warn {
+			return false, []Reply{{Msg: t.options.Warning}}
+		}
+	case ThrottleHook:
+		if t.options.Hook != nil {
+			return false, t.options.Hook(m, info)
+		}
+	}
+	return false, nil
+}
+
+func (t *throttler) allow(m *Message, commands []string, now time.Time) (ThrottleInfo, string, bool) {
+	userKey := "user:" + strconv.FormatInt(m.UserID, 10)
+	peerKey := "peer:" + strconv.FormatInt(m.PeerID, 10)
+	for _, cmd := range commands {
+		cooldown, ok := t.options.Cooldowns[cmd]
+		if !ok {
+			continue
+		}
+		key := userKey + ":" + cmd
+		if last, ok := t.cooldowns[key]; ok && now.Sub(last) < cooldown {
+			info := ThrottleInfo{Limit: ThrottleLimitCooldown, Command: cmd, RetryAfter: cooldown - now.Sub(last)}
+			return info, key, false
+		}
+	}
+	if after, ok := t.hit(userKey, t.options.User, now, false); !ok {
+		return ThrottleInfo{Limit: ThrottleLimitUser, RetryAfter: after}, userKey, false
+	}
+	if after, ok := t.hit(peerKey, t.options.Peer, now, false); !ok {
+		return ThrottleInfo{Limit: ThrottleLimitPeer, RetryAfter: after}, peerKey, false
+	}
+	t.hit(userKey, t.options.User, now, true)
+	t.hit(peerKey, t.options.Peer, now, true)
+	for _, cmd := range commands {
+		if _, ok := t.options.Cooldowns[cmd]; ok {
+			t.cooldowns[userKey+":"+cmd] = now
+		}
+	}
+	return ThrottleInfo{}, "", true
+}
+
+// hit - sliding window check. Records hit if save is true
+func (t *throttler) hit(key string, limit RateLimit, now time.Time, save bool) (time.Duration, bool) {
+	if limit.Count <= 0 {
+		return 0, true
+	}
+	hits := t.hits[key]
+	from := now.Add(-limit.Interval)
+	i := 0
+	for i < len(hits) && !hits[i].After(from) {
+		i++
+	}
+	hits = hits[i:]
+	if len(hits) >= limit.Count {
+		t.hits[key] = hits
+		return hits[0].Sub(from), false
+	}
+	if save {
+		hits = append(hits, now)
+	}
+	if len(hits) == 0 {
+		delete(t.hits, key)
+	} else {
+		t.hits[key] = hits
+	}
+	return 0, true
+}
+
+// sweep - delete outdated entries once a minute
+func (t *throttler) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < time.Minute {
+		return
+	}
+	t.lastSweep = now
+	maxInterval := t.options.User.Interval
+	if t.options.Peer.Interval > maxInterval {
+		maxInterval = t.options.Peer.Interval
+	}
+	for key, hits := range t.hits {
+		if len(hits) == 0 || now.Sub(hits[len(hits)-1]) > maxInterval {
+			delete(t.hits, key)
+		}
+	}
+	for key, until := range t.warned {
+		if now.After(until) {
+			delete(t.warned, key)
+		}
+	}
+	for key, last := range t.cooldowns {
+		expired := true
+		for _, cooldown := range t.options.Cooldowns {
+			if now.Sub(last) < cooldown {
+				expired = false
+				break
+			}
+		}
+		if expired {
+			delete(t.cooldowns, key)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package govkbot
+
+import (
+	"testing"
+	"time"
+)
+
+func routeCount(bot *VKBot, m Message, n int) (replies []Reply) {
+	for i := 0; i < n; i++ {
+		r, _ := bot.RouteMessage(&m)
+		replies = append(replies, r...)
+	}
+	return replies
+}
+
+func TestVKBot_SetThrottle(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	bot.HandleMessage("/help", baseHandler)
+	bot.SetThrottle(ThrottleOptions{
+		User:   RateLimit{Count: 2, Interval: time.Minute},
+		Action: ThrottleWarn,
+	})
+	replies := routeCount(bot, Message{Body: "/help", UserID: 1, PeerID: 1}, 5)
+	if len(replies) != 3 || replies[2].Msg != DefaultThrottleWarning {
+		t.Errorf("wrong replies: %+v", replies)
+	}
+	replies = routeCount(bot, Message{Body: "/help", UserID: 2, PeerID: 1}, 1)
+	if len(replies) != 1 || replies[0].Msg != "/help" {
+		t.Errorf("other user throttled: %+v", replies)
+	}
+	replies = routeCount(bot, Message{Body: "hello", UserID: 1, PeerID: 1}, 1)
+	if len(replies) != 0 {
+		t.Errorf("not commands must be ignored: %+v", replies)
+	}
+}
+
+func TestThrottle_PeerAndCooldown(t *testing.T) {
+	SetAPI("", "test", "")
+	bot := API.NewBot()
+	bot.HandleMessage("/help", baseHandler)
+	bot.HandleMessage("/top", baseHandler)
+	var infos []ThrottleInfo
+	bot.SetThrottle(ThrottleOptions{
+		Peer:      RateLimit{Count: 3, Interval: time.Minute},
+		Cooldowns: map[string]time.Duration{"/top": time.Minute},
+		Action:    ThrottleHook,
+		Hook: func(m *Message, info ThrottleInfo) []Reply {
+			infos = append(infos, info)
+			return nil
+		},
+	})
+	routeCount(bot, Message{Body: "/top", UserID: 1, PeerID: 1}, 2)
+	routeCount(bot, Message{Body: "/help", UserID: 2, PeerID: 1}, 3)
+	if len(infos) != 2 {
+		t.Fatalf("wrong throttle infos: %+v", infos)
+	}
+	if infos[0].Limit != ThrottleLimitCooldown || infos[0].Command != "/top" || infos[0].RetryAfter <= 0 {
+		t.Errorf("wrong cooldown info: %+v", infos[0])
+	}
+	if infos[1].Limit != ThrottleLimitPeer {
+		t.Errorf("wrong peer info: %+v", infos[1])
+	}
+}
+
+func TestThrottle_Window(t *testing.T) {
+	th := &throttler{hits: make(map[string][]time.Time)}
+	limit := RateLimit{Count: 1, Interval: time.Second}
+	now := time.Now()
+	if _, ok := th.hit("k", limit, now, true); !ok {
+		t.Error("first hit throttled")
+	}
+	if after, ok := th.hit("k", limit, now.Add(time.Millisecond), true); ok || after <= 0 {
+		t.Error("second hit allowed")
+	}
+	if _, ok := th.hit("k", limit, now.Add(2*time.Second), true); !ok {
+		t.Error("hit after interval throttled")
+	}
+}
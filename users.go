@@ -0,0 +1,180 @@
+package govkbot
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const apiUtilsResolveScreenName = "utils.resolveScreenName"
+
+// usersBatchSize - max user ids in one users.get call
+const usersBatchSize = 1000
+
+// Name cases for Users
+const (
+	NameCaseNom = "nom" // nominative
+	NameCaseGen = "gen" // genitive
+	NameCaseDat = "dat" // dative
+	NameCaseAcc = "acc" // accusative
+	NameCaseIns = "ins" // instrumental
+	NameCaseAbl = "abl" // prepositional
+)
+
+// DefaultUserFields - fields requested by User
+var DefaultUserFields = []string{"sex", "screen_name", "city", "country", "bdate"}
+
+// ErrScreenNameNotFound - screen name not exists
+var ErrScreenNameNotFound = errors.New("vkapi: screen name not found")
+
+// ScreenNameObject - resolved screen name. Type is user, group or application
+type ScreenNameObject struct {
+	Type     string `json:"type"`
+	ObjectID int64  `json:"object_id"`
+}
+
+// Users - get users info with fields in name case ("" is nominative).
+// Requests are batched by 1000 ids, results are cached. Deleted and unknown users are skipped
+func (api *VkAPI) Users(ids []int64, fields []string, nameCase string) ([]*User, error) {
+	fields = normalizeFields(fields)
+	suffix := ":" + strings.Join(fields, ",") + ":" + nameCase + ":" + api.Lang
+	found := make(map[int64]*User, len(ids))
+	var missing []int64
+	for _, id := range ids {
+		if _, ok := found[id]; ok {
+			continue
+		}
+		if cached, ok := api.cacheGet("user:" + strconv.FormatInt(id, 10) + suffix); ok {
+			u := cached.(User)
+			found[id] = &u
+		} else {
+			found[id] = nil
+			missing = append(missing, id)
+		}
+	}
+	for len(missing) > 0 {
+		n := usersBatchSize
+		if n > len(missing) {
+			n = len(missing)
+		}
+		users, err := api.getUsers(missing[:n], fields, nameCase)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			api.cacheSet("user:"+strconv.FormatInt(u.ID, 10)+suffix, *u)
+			found[u.ID] = u
+		}
+		missing = missing[n:]
+	}
+	result := make([]*User, 0, len(ids))
+	for _, id := range ids {
+		if u := found[id]; u != nil {
+			result = append(result, u)
+			found[id] = nil
+		}
+	}
+	return result, nil
+}
+
+func (api *VkAPI) getUsers(ids []int64, fields []string, nameCase string) ([]*User, error) {
+	params := H{"user_ids": joinIDs(ids)}
+	if len(fields) > 0 {
+		params["fields"] = strings.Join(fields, ",")
+	}
+	if nameCase != "" {
+		params["name_case"] = nameCase
+	}
+	r := struct {
+		Response []json.RawMessage
+	}{}
+	if err := api.CallMethod(apiUsersGet, params, &r); err != nil {
+		return nil, err
+	}
+	users := make([]*User, 0, len(r.Response))
+	for _, raw := range r.Response {
+		u := &User{}
+		if err := json.Unmarshal(raw, u); err != nil {
+			return nil, err
+		}
+		u.Raw = raw
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// normalizeFields - trim, dedup and sort fields to make cache keys stable
+func normalizeFields(fields []string) []string {
+	result := make([]string, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f != "" && !seen[f] {
+			seen[f] = true
+			result = append(result, f)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ResolveScreenName - resolve screen name (or vk.com link) to object type and id. Results are cached
+func (api *VkAPI) ResolveScreenName(name string) (*ScreenNameObject, error) {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	key := "screen_name:" + strings.ToLower(name)
+	if cached, ok := api.cacheGet(key); ok {
+		obj := cached.(ScreenNameObject)
+		return &obj, nil
+	}
+	r := RawResponse{}
+	if err := api.CallMethod(apiUtilsResolveScreenName, H{"screen_name": name}, &r); err != nil {
+		return nil, err
+	}
+	obj := ScreenNameObject{}
+	// unknown screen name returns empty array
+	if len(r.Response) == 0 || r.Response[0] != '{' {
+		return nil, ErrScreenNameNotFound
+	}
+	if err := json.Unmarshal(r.Response, &obj); err != nil {
+		return nil, err
+	}
+	api.cacheSet(key, obj)
+	return &obj, nil
+}
+
+// UserByScreenName - get user info by screen name
+func (api *VkAPI) UserByScreenName(name string, fields []string, nameCase string) (*User, error) {
+	obj, err := api.ResolveScreenName(name)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Type != "user" {
+		return nil, ErrScreenNameNotFound
+	}
+	users, err := api.Users([]int64{obj.ObjectID}, fields, nameCase)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, errors.New("no users returned")
+	}
+	return users[0], nil
+}
+
+// Field - decode additional field requested in Users
+func (u *User) Field(name string, v interface{}) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(u.Raw, &fields); err != nil {
+		return err
+	}
+	raw, ok := fields[name]
+	if !ok {
+		return errors.New("vkapi: user has no field " + name)
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,53 @@
+package govkbot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVkAPI_Users(t *testing.T) {
+	SetAPI("", "test", "")
+	API.SetCache(time.Minute, 100)
+	defer API.SetCache(0, 0)
+	users, err := API.Users([]int64{1, 2, 1}, []string{" city", "photo_50", "city"}, NameCaseGen)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(users) != 1 || users[0].ID != 1 || users[0].City.Title != "Moscow" {
+		t.Fatalf("wrong users: %+v", users)
+	}
+	var verified int
+	if err = users[0].Field("verified", &verified); err != nil {
+		t.Error(err.Error())
+	}
+	if _, ok := API.cacheGet("user:1:city,photo_50:gen:" + API.Lang); !ok {
+		t.Error("user not cached")
+	}
+	users[0].FirstName = "changed"
+	users, _ = API.Users([]int64{1}, []string{"photo_50", "city"}, NameCaseGen)
+	if len(users) != 1 || users[0].FirstName != "First" {
+		t.Errorf("wrong cached users: %+v", users)
+	}
+}
+
+func TestVkAPI_ResolveScreenName(t *testing.T) {
+	SetAPI("", "test", "")
+	obj, err := API.ResolveScreenName("https://vk.com/durov")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if obj.Type != "user" || obj.ObjectID != 1 {
+		t.Errorf("wrong object: %+v", obj)
+	}
+	u, err := API.UserByScreenName("@durov", nil, "")
+	if err != nil || u.ID != 1 {
+		t.Errorf("wrong user: %+v %+v", u, err)
+	}
+}
+
+func TestNormalizeFields(t *testing.T) {
+	fields := normalizeFields([]string{"sex", " city", "", "sex"})
+	if len(fields) != 2 || fields[0] != "city" || fields[1] != "sex" {
+		t.Errorf("wrong fields: %+v", fields)
+	}
+}
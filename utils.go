@@ -1,6 +1,12 @@
 package govkbot
 
-import "strings"
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
 
 // HasPrefix tests case insensitive whether the string s begins with prefix.
 func HasPrefix(s, prefix string) bool {
@@ -15,3 +21,33 @@ func TrimPrefix(s, prefix string) string {
 	}
 	return s
 }
+
+// writeJSONFile writes v to file as JSON. File replaced atomically via temp file
+func writeJSONFile(path string, v interface{}) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// joinIDs joins ids with comma for VK API list params
+func joinIDs(ids []int64) string {
+	s := make([]string, 0, len(ids))
+	for _, id := range ids {
+		s = append(s, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(s, ",")
+}
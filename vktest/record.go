@@ -0,0 +1,277 @@
+package vktest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Redacted - value of secret params in recordings
+const Redacted = "REDACTED"
+
+// Pseudo methods of recorded non API requests
+const (
+	MethodLongPoll = "longpoll"
+	MethodUpload   = "upload"
+)
+
+// secretParams - params and response fields never written to recordings
+var secretParams = map[string]bool{"access_token": true, "key": true}
+
+// Interaction - recorded request and response, one JSONL line.
+// Non JSON response, like HTML error page, is recorded as JSON string with Raw flag
+type Interaction struct {
+	Method   string          `json:"method"`
+	Params   url.Values      `json:"params,omitempty"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response"`
+	Raw      bool            `json:"raw,omitempty"`
+}
+
+// Recorder - http.RoundTripper writing VK API interactions to JSONL with secrets redacted:
+//
+//	f, _ := os.Create("testdata/session.jsonl")
+//	api.HTTPClient = vktest.NewRecorder(f, nil).Client()
+type Recorder struct {
+	Transport http.RoundTripper // real transport, http.DefaultTransport if nil
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewRecorder - create recorder writing to w
+func NewRecorder(w io.Writer, transport http.RoundTripper) *Recorder {
+	return &Recorder{Transport: transport, w: w}
+}
+
+// Client - http client with recorder transport
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip - send request with real transport and record it
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	method, params, err := requestParams(req)
+	if err != nil {
+		return nil, err
+	}
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{Method: method, Params: redactParams(params), Status: resp.StatusCode}
+	if interaction.Response, err = redactJSON(body); err != nil {
+		interaction.Raw = true
+		if interaction.Response, err = json.Marshal(string(body)); err != nil {
+			return nil, fmt.Errorf("vktest: record %s: %w", method, err)
+		}
+	}
+	line, err := json.Marshal(interaction)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err = r.w.Write(append(line, '\n')); err != nil {
+		return nil, fmt.Errorf("vktest: record %s: %w", method, err)
+	}
+	return resp, nil
+}
+
+// Replayer - http.RoundTripper serving recorded responses. Request matches first unused
+// interaction with same method and params, secret and ignored params are not compared
+type Replayer struct {
+	Ignore map[string]bool // params not compared, random_id by default
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer - create replayer from JSONL recording
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	replayer := &Replayer{Ignore: map[string]bool{"random_id": true}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var i Interaction
+		if err := json.Unmarshal(line, &i); err != nil {
+			return nil, fmt.Errorf("vktest: recording line %d: %w", n, err)
+		}
+		replayer.interactions = append(replayer.interactions, i)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	replayer.used = make([]bool, len(replayer.interactions))
+	return replayer, nil
+}
+
+// LoadReplayer - create replayer from JSONL recording file
+func LoadReplayer(filename string) (*Replayer, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewReplayer(f)
+}
+
+// Client - http client with replayer transport
+func (r *Replayer) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Unused - recorded interactions not requested yet
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Interaction
+	for i, used := range r.used {
+		if !used {
+			unused = append(unused, r.interactions[i])
+		}
+	}
+	return unused
+}
+
+// RoundTrip - serve recorded response of request
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	method, params, err := requestParams(req)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Method != method || !r.match(interaction.Params, params) {
+			continue
+		}
+		body, contentType := []byte(interaction.Response), "application/json"
+		if interaction.Raw {
+			var raw string
+			if err = json.Unmarshal(interaction.Response, &raw); err != nil {
+				return nil, fmt.Errorf("vktest: replay %s: %w", method, err)
+			}
+			body, contentType = []byte(raw), "text/plain; charset=utf-8"
+		}
+		r.used[i] = true
+		return &http.Response{
+			StatusCode:    interaction.Status,
+			Status:        http.StatusText(interaction.Status),
+			Header:        http.Header{"Content-Type": {contentType}},
+			Body:          ioutil.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("vktest: no recorded response for %s %s", method, redactParams(params).Encode())
+}
+
+func (r *Replayer) match(recorded url.Values, params url.Values) bool {
+	for _, values := range []url.Values{recorded, params} {
+		for k := range values {
+			if secretParams[k] || r.Ignore[k] {
+				continue
+			}
+			if strings.Join(recorded[k], ",") != strings.Join(params[k], ",") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// requestParams - API method or pseudo method and params of request. Body is restored for sending
+func requestParams(req *http.Request) (string, url.Values, error) {
+	params := url.Values{}
+	for k, v := range req.URL.Query() {
+		params[k] = v
+	}
+	method := MethodUpload
+	if i := strings.Index(req.URL.Path, "/method/"); i >= 0 {
+		method = req.URL.Path[i+len("/method/"):]
+	} else if params.Get("act") == "a_check" {
+		method = MethodLongPoll
+	}
+	if req.Body == nil || !strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return method, params, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", nil, fmt.Errorf("vktest: parse %s params: %w", method, err)
+	}
+	for k, v := range form {
+		params[k] = v
+	}
+	return method, params, nil
+}
+
+func redactParams(params url.Values) url.Values {
+	redacted := url.Values{}
+	for k, v := range params {
+		if secretParams[k] {
+			v = []string{Redacted}
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
+
+// redactJSON - replace secret fields of response, like long poll key
+func redactJSON(body []byte) (json.RawMessage, error) {
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redactValue(v))
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if secretParams[k] {
+				v[k] = Redacted
+			} else {
+				v[k] = redactValue(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,95 @@
+package vktest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nikepan/govkbot/v2"
+)
+
+func TestRecordReplay(t *testing.T) {
+	srv := NewServer(t)
+	recording := &bytes.Buffer{}
+	api := srv.API()
+	api.HTTPClient = NewRecorder(recording, nil).Client()
+
+	id, err := api.SendPeerMessage(5, "hello")
+	if err != nil || id == 0 {
+		t.Fatal(id, err)
+	}
+	server := &govkbot.GroupLongPollServer{API: api, Wait: 1}
+	if err = server.Init(); err != nil {
+		t.Fatal(err)
+	}
+	srv.PushMessage(IncomingMessage{FromID: 5, Text: "/start"})
+	messages, err := server.GetLongPollMessages()
+	if err != nil || len(messages) != 1 {
+		t.Fatal(messages, err)
+	}
+
+	text := recording.String()
+	if strings.Contains(text, "test-token") || strings.Contains(text, "test-key") {
+		t.Errorf("secrets in recording: %s", text)
+	}
+	if lines := strings.Count(text, "\n"); lines != 3 {
+		t.Errorf("wrong recorded interactions count %d: %s", lines, text)
+	}
+
+	replayer, err := NewReplayer(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api = &govkbot.VkAPI{Token: "other-token", URL: "https://api.vk.com/method/", Ver: "5.154", GroupID: DefaultGroupID, HTTPClient: replayer.Client()}
+	replayed, err := api.SendPeerMessage(5, "hello")
+	if err != nil || replayed != id {
+		t.Errorf("wrong replayed send: %d %v", replayed, err)
+	}
+	server = &govkbot.GroupLongPollServer{API: api, Wait: 1}
+	messages, err = server.GetLongPollMessages()
+	if err != nil || len(messages) != 1 || messages[0].Body != "/start" {
+		t.Errorf("wrong replayed long poll: %+v %v", messages, err)
+	}
+	if unused := replayer.Unused(); len(unused) != 0 {
+		t.Errorf("unused interactions: %+v", unused)
+	}
+
+	if _, err = api.SendPeerMessage(5, "other"); err == nil {
+		t.Error("no error for not recorded request")
+	}
+}
+
+func TestRecordReplayRaw(t *testing.T) {
+	page := "<html><body>502 Bad Gateway</body></html>"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(page))
+	}))
+	defer srv.Close()
+	recording := &bytes.Buffer{}
+	resp, err := NewRecorder(recording, nil).Client().Get(srv.URL + "/method/users.get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusBadGateway || string(body) != page {
+		t.Errorf("wrong recorded response: %d %s", resp.StatusCode, body)
+	}
+
+	replayer, err := NewReplayer(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = replayer.Client().Get("https://api.vk.com/method/users.get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusBadGateway || string(body) != page {
+		t.Errorf("wrong replayed response: %d %s", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,403 @@
+// Package vktest - in-process fake VK API server for bot tests.
+//
+//	bot, srv := vktest.NewBot(t)
+//	bot.Handle("/help", helpHandler)
+//	srv.Run(t, bot)
+//	srv.PushMessage(vktest.IncomingMessage{PeerID: 1, FromID: 1, Text: "/help"})
+//	sent := srv.WaitSent(t, 1)
+package vktest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nikepan/govkbot/v2"
+)
+
+// DefaultGroupID - group id of fake bot
+const DefaultGroupID = 1
+
+// DefaultTimeout - WaitSent timeout
+var DefaultTimeout = 5 * time.Second
+
+// MethodHandler - custom method response. Returned value is encoded to "response" field,
+// *govkbot.VKError is returned as VK error
+type MethodHandler func(params url.Values) (interface{}, error)
+
+// Call - recorded API call
+type Call struct {
+	Method string
+	Params url.Values
+}
+
+// Sent - recorded messages.send call, one per peer
+type Sent struct {
+	PeerID    int64
+	MessageID int64
+	Message   string
+	Keyboard  string
+	Template  string
+	Params    url.Values
+}
+
+// IncomingMessage - message from user for PushMessage
+type IncomingMessage struct {
+	PeerID     int64
+	FromID     int64
+	Text       string
+	Payload    string
+	Action     string
+	MemberID   int64
+	ClientInfo *govkbot.ClientInfo // default is client with all features
+}
+
+// Server - fake VK API server with long poll
+type Server struct {
+	*httptest.Server
+	GroupID int64
+
+	mu       sync.Mutex
+	handlers map[string]MethodHandler
+	errors   map[string]*govkbot.VKError
+	calls    []Call
+	sent     []Sent
+	updates  []json.RawMessage
+	wake     chan struct{}
+	done     chan struct{}
+	closed   bool
+	polling  chan struct{}
+	lastID   int64
+}
+
+// NewServer - start fake VK server, closed on test cleanup
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		GroupID:  DefaultGroupID,
+		handlers: make(map[string]MethodHandler),
+		errors:   make(map[string]*govkbot.VKError),
+		wake:     make(chan struct{}),
+		done:     make(chan struct{}),
+		polling:  make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/method/", s.serveMethod)
+	mux.HandleFunc("/longpoll", s.serveLongPoll)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// NewBot - start fake server and create group bot connected to it. Bot errors are logged to test
+func NewBot(t testing.TB) (*govkbot.VKBot, *Server) {
+	s := NewServer(t)
+	bot := s.API().NewBot()
+	bot.HandleError(func(m *govkbot.Message, err error) {
+		t.Logf("bot error: %+v", err)
+	})
+	options := govkbot.DefaultPollOptions()
+	options.Wait = 1
+	options.MinBackoff = 10 * time.Millisecond
+	options.MaxBackoff = 100 * time.Millisecond
+	bot.SetPollOptions(options)
+	return bot, s
+}
+
+// API - create group API connected to server
+func (s *Server) API() *govkbot.VkAPI {
+	return &govkbot.VkAPI{
+		Token:         "test-token",
+		URL:           s.URL + "/method/",
+		Ver:           "5.154",
+		GroupID:       s.GroupID,
+		MessagesCount: 200,
+	}
+}
+
+// Run - start bot long poll listening and wait for first long poll request.
+// Bot stopped on test cleanup
+func (s *Server) Run(t testing.TB, bot *govkbot.VKBot) {
+	t.Helper()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		bot.ListenGroup(bot.API)
+	}()
+	t.Cleanup(func() {
+		bot.Stop()
+		s.interrupt()
+		<-stopped
+	})
+	select {
+	case <-s.polling:
+	case <-time.After(DefaultTimeout):
+		t.Fatal("vktest: bot not started long poll")
+	}
+}
+
+// Close - stop server
+func (s *Server) Close() {
+	s.interrupt()
+	s.Server.Close()
+}
+
+func (s *Server) interrupt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+}
+
+// Handle - set custom method response
+func (s *Server) Handle(method string, handler MethodHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = handler
+}
+
+// SetError - method returns VK error until ClearError
+func (s *Server) SetError(method string, code int, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[method] = &govkbot.VKError{ErrorCode: code, ErrorMsg: msg}
+}
+
+// ClearError - method works again
+func (s *Server) ClearError(method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.errors, method)
+}
+
+// Calls - recorded calls of method, all calls if method is ""
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []Call
+	for _, c := range s.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Sent - recorded sent messages
+func (s *Server) Sent() []Sent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Sent(nil), s.sent...)
+}
+
+// WaitSent - wait until at least n messages sent, fails test on timeout
+func (s *Server) WaitSent(t testing.TB, n int) []Sent {
+	t.Helper()
+	deadline := time.Now().Add(DefaultTimeout)
+	for {
+		sent := s.Sent()
+		if len(sent) >= n {
+			return sent
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("vktest: %d messages sent, expected %d", len(sent), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// PushUpdate - add raw long poll update, e.g. {"type": "message_new", "object": {...}}
+func (s *Server) PushUpdate(update interface{}) {
+	buf, err := json.Marshal(update)
+	if err != nil {
+		panic(err)
+	}
+	s.mu.Lock()
+	s.updates = append(s.updates, buf)
+	close(s.wake)
+	s.wake = make(chan struct{})
+	s.mu.Unlock()
+}
+
+// PushMessage - add message_new long poll update. Returns message id
+func (s *Server) PushMessage(m IncomingMessage) int64 {
+	s.mu.Lock()
+	s.lastID++
+	id := s.lastID
+	s.mu.Unlock()
+	if m.PeerID == 0 {
+		m.PeerID = m.FromID
+	}
+	message := map[string]interface{}{
+		"id":                      id,
+		"date":                    time.Now().Unix(),
+		"peer_id":                 m.PeerID,
+		"from_id":                 m.FromID,
+		"text":                    m.Text,
+		"out":                     0,
+		"conversation_message_id": id,
+	}
+	if m.Payload != "" {
+		message["payload"] = m.Payload
+	}
+	if m.Action != "" {
+		message["action"] = map[string]interface{}{"type": m.Action, "member_id": m.MemberID}
+	}
+	clientInfo := m.ClientInfo
+	if clientInfo == nil {
+		clientInfo = &govkbot.ClientInfo{
+			ButtonActions:  []string{"text", "vkpay", "open_app", "location", "open_link", "callback", "intent_subscribe", "intent_unsubscribe"},
+			Keyboard:       true,
+			InlineKeyboard: true,
+			Carousel:       true,
+		}
+	}
+	s.PushUpdate(map[string]interface{}{
+		"type":     "message_new",
+		"group_id": s.GroupID,
+		"event_id": strconv.FormatInt(id, 10),
+		"object":   map[string]interface{}{"message": message, "client_info": clientInfo},
+	})
+	return id
+}
+
+func (s *Server) serveMethod(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/method/")
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := r.Form
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: method, Params: params})
+	vkErr := s.errors[method]
+	handler := s.handlers[method]
+	s.mu.Unlock()
+
+	var response interface{}
+	var err error
+	switch {
+	case vkErr != nil:
+		err = vkErr
+	case handler != nil:
+		response, err = handler(params)
+	default:
+		response, err = s.defaultResponse(method, params)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		e, ok := err.(*govkbot.VKError)
+		if !ok {
+			e = &govkbot.VKError{ErrorCode: 1, ErrorMsg: err.Error()}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": e})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"response": response})
+}
+
+func (s *Server) defaultResponse(method string, params url.Values) (interface{}, error) {
+	switch method {
+	case "groups.getLongPollServer":
+		s.mu.Lock()
+		ts := len(s.updates)
+		s.mu.Unlock()
+		return map[string]interface{}{"server": s.URL + "/longpoll", "key": "test-key", "ts": strconv.Itoa(ts)}, nil
+	case "groups.getById":
+		return map[string]interface{}{
+			"groups": []map[string]interface{}{{"id": s.GroupID, "name": "Test bot", "screen_name": "testbot", "type": "group"}},
+		}, nil
+	case "messages.send":
+		return s.send(params), nil
+	case "messages.edit", "messages.delete", "messages.setActivity", "messages.markAsRead",
+		"messages.pin", "messages.unpin", "messages.removeChatUser", "messages.editChat":
+		return 1, nil
+	}
+	return nil, &govkbot.VKError{ErrorCode: 3, ErrorMsg: "Unknown method passed: " + method}
+}
+
+func (s *Server) send(params url.Values) interface{} {
+	peers := params.Get("peer_ids")
+	single := peers == ""
+	if single {
+		peers = params.Get("peer_id")
+		if peers == "" {
+			peers = params.Get("user_id")
+		}
+	}
+	var result []map[string]interface{}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range strings.Split(peers, ",") {
+		peerID, _ := strconv.ParseInt(p, 10, 64)
+		s.lastID++
+		s.sent = append(s.sent, Sent{
+			PeerID:    peerID,
+			MessageID: s.lastID,
+			Message:   params.Get("message"),
+			Keyboard:  params.Get("keyboard"),
+			Template:  params.Get("template"),
+			Params:    params,
+		})
+		result = append(result, map[string]interface{}{
+			"peer_id":                 peerID,
+			"message_id":              s.lastID,
+			"conversation_message_id": s.lastID,
+		})
+	}
+	if single {
+		return s.lastID
+	}
+	return result
+}
+
+func (s *Server) serveLongPoll(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	select {
+	case <-s.polling:
+	default:
+		close(s.polling)
+	}
+	s.mu.Unlock()
+	ts, _ := strconv.Atoi(r.URL.Query().Get("ts"))
+	wait, _ := strconv.Atoi(r.URL.Query().Get("wait"))
+	timeout := time.After(time.Duration(wait) * time.Second)
+	for {
+		s.mu.Lock()
+		var updates []json.RawMessage
+		if ts < len(s.updates) {
+			updates = s.updates[ts:]
+		}
+		next := len(s.updates)
+		wake := s.wake
+		s.mu.Unlock()
+		if len(updates) > 0 {
+			writeLongPoll(w, next, updates)
+			return
+		}
+		select {
+		case <-wake:
+		case <-s.done:
+			writeLongPoll(w, next, nil)
+			return
+		case <-timeout:
+			writeLongPoll(w, next, nil)
+			return
+		}
+	}
+}
+
+func writeLongPoll(w http.ResponseWriter, ts int, updates []json.RawMessage) {
+	if updates == nil {
+		updates = []json.RawMessage{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"ts": strconv.Itoa(ts), "updates": updates})
+}
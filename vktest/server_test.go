@@ -0,0 +1,69 @@
+package vktest
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/nikepan/govkbot/v2"
+)
+
+func TestServer_Bot(t *testing.T) {
+	bot, srv := NewBot(t)
+	bot.Handle("/help", func(c *govkbot.Context) error {
+		c.Reply("help: " + c.ArgsString())
+		return nil
+	})
+	bot.Handle("/menu", func(c *govkbot.Context) error {
+		c.ReplyKeyboard("menu", &govkbot.Keyboard{Buttons: [][]govkbot.Button{{govkbot.NewButton("/help", nil)}}})
+		return nil
+	})
+	srv.Run(t, bot)
+
+	srv.PushMessage(IncomingMessage{FromID: 5, Text: "/help me"})
+	sent := srv.WaitSent(t, 1)
+	if sent[0].PeerID != 5 || sent[0].Message != "help: me" {
+		t.Errorf("wrong sent message: %+v", sent[0])
+	}
+
+	srv.PushMessage(IncomingMessage{FromID: 5, Text: "/menu", ClientInfo: &govkbot.ClientInfo{ButtonActions: []string{"text"}}})
+	sent = srv.WaitSent(t, 2)
+	if sent[1].Keyboard != "" || sent[1].Message != "menu\n\n1. /help" {
+		t.Errorf("wrong fallback menu: %+v", sent[1])
+	}
+	if len(srv.Calls("groups.getLongPollServer")) != 1 {
+		t.Errorf("wrong long poll init calls: %+v", srv.Calls("groups.getLongPollServer"))
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	srv := NewServer(t)
+	api := srv.API()
+	srv.SetError("messages.send", 901, "Can't send messages for users without permission")
+	_, err := api.SendAdvancedPeerMessage(1, govkbot.Reply{Msg: "hi"})
+	var vkErr *govkbot.VKError
+	if !errors.As(err, &vkErr) || vkErr.ErrorCode != 901 {
+		t.Errorf("wrong error: %+v", err)
+	}
+	srv.ClearError("messages.send")
+	if _, err = api.SendAdvancedPeerMessage(1, govkbot.Reply{Msg: "hi"}); err != nil {
+		t.Error(err.Error())
+	}
+
+	srv.Handle("messages.getInviteLink", func(params url.Values) (interface{}, error) {
+		return map[string]string{"link": "https://vk.me/join/" + params.Get("peer_id")}, nil
+	})
+	link, err := api.GetInviteLink(2000000001, false)
+	if err != nil || link != "https://vk.me/join/2000000001" {
+		t.Errorf("wrong link: %s %+v", link, err)
+	}
+	if _, err = api.GetInviteLink(2000000001, false); err != nil {
+		t.Error(err.Error())
+	}
+	if err = api.EditChat(1, "title"); err != nil {
+		t.Error(err.Error())
+	}
+	if calls := srv.Calls("messages.editChat"); len(calls) != 1 || calls[0].Params.Get("chat_id") != "1" {
+		t.Errorf("wrong calls: %+v", calls)
+	}
+}